@@ -0,0 +1,222 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+// Package agecrypt implements a keyring for age encrypted password files.
+//
+// Recipients are X25519 public keys in the "age1..." form. A special recipient
+// named "scrypt" encrypts the files with a passphrase instead, in which case it
+// must be the only recipient.
+package agecrypt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"golang.org/x/xerrors"
+
+	"github.com/bvk/past/gpg"
+)
+
+// ScryptRecipient is the recipient name for passphrase encrypted files.
+const ScryptRecipient = "scrypt"
+
+// PassphraseFunc returns the passphrase for scrypt encrypted files.
+type PassphraseFunc func() (string, error)
+
+type Keyring struct {
+	file string
+
+	passphrase PassphraseFunc
+
+	identities []*age.X25519Identity
+}
+
+// DefaultIdentitiesFile returns the default location for the age identities
+// file.
+func DefaultIdentitiesFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".config/past/age-identities")
+}
+
+// NewKeyring creates a keyring with the identities from an age identities
+// file, which can be created with the age-keygen tool. Identities file is
+// optional when passphrase function is non-nil.
+func NewKeyring(file string, passphrase PassphraseFunc) (*Keyring, error) {
+	k := &Keyring{file: file, passphrase: passphrase}
+	if err := k.Refresh(); err != nil {
+		return nil, xerrors.Errorf("could not load age identities: %w", err)
+	}
+	if len(k.identities) == 0 && passphrase == nil {
+		return nil, os.ErrNotExist
+	}
+	return k, nil
+}
+
+// Refresh reloads the identities from the identities file. A missing
+// identities file is not an error.
+func (k *Keyring) Refresh() error {
+	data, err := ioutil.ReadFile(k.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			k.identities = nil
+			return nil
+		}
+		return xerrors.Errorf("could not read identities file %q: %w", k.file, err)
+	}
+	ids, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return xerrors.Errorf("could not parse identities file %q: %w", k.file, err)
+	}
+	var identities []*age.X25519Identity
+	for _, id := range ids {
+		if xid, ok := id.(*age.X25519Identity); ok {
+			identities = append(identities, xid)
+		}
+	}
+	k.identities = identities
+	return nil
+}
+
+func (k *Keyring) Encrypt(data []byte, recipients []string) ([]byte, error) {
+	var rs []age.Recipient
+	for _, r := range recipients {
+		if r == ScryptRecipient {
+			if len(recipients) != 1 {
+				return nil, xerrors.Errorf("scrypt recipient must be the only recipient: %w", os.ErrInvalid)
+			}
+			if k.passphrase == nil {
+				return nil, xerrors.Errorf("passphrase is required for scrypt recipient: %w", os.ErrInvalid)
+			}
+			passphrase, err := k.passphrase()
+			if err != nil {
+				return nil, xerrors.Errorf("could not get passphrase: %w", err)
+			}
+			sr, err := age.NewScryptRecipient(passphrase)
+			if err != nil {
+				return nil, xerrors.Errorf("could not create scrypt recipient: %w", err)
+			}
+			rs = append(rs, sr)
+			continue
+		}
+		xr, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return nil, xerrors.Errorf("could not parse age recipient %q: %w", r, err)
+		}
+		rs = append(rs, xr)
+	}
+
+	var buffer bytes.Buffer
+	w, err := age.Encrypt(&buffer, rs...)
+	if err != nil {
+		return nil, xerrors.Errorf("could not encrypt input data: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, xerrors.Errorf("could not encrypt input data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, xerrors.Errorf("could not finish encrypting input data: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	var ids []age.Identity
+	for _, id := range k.identities {
+		ids = append(ids, id)
+	}
+	if k.passphrase != nil {
+		ids = append(ids, &scryptIdentity{passphrase: k.passphrase})
+	}
+	if len(ids) == 0 {
+		return nil, xerrors.Errorf("no age identities are available: %w", os.ErrNotExist)
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), ids...)
+	if err != nil {
+		return nil, xerrors.Errorf("could not decrypt input data: %w", err)
+	}
+	decrypted, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, xerrors.Errorf("could not read decrypted data: %w", err)
+	}
+	return decrypted, nil
+}
+
+// Recipients always returns an empty list because age encrypted data doesn't
+// identify the recipients.
+func (k *Keyring) Recipients(data []byte) ([]string, error) {
+	return nil, nil
+}
+
+// PublicKeys returns the recipients for the identities in the keyring.
+func (k *Keyring) PublicKeys() []*gpg.PublicKey {
+	var pks []*gpg.PublicKey
+	for _, id := range k.identities {
+		r := id.Recipient().String()
+		pks = append(pks, &gpg.PublicKey{
+			KeyID:       r,
+			Fingerprint: r,
+			CanEncrypt:  true,
+			Trusted:     true,
+			KeyLength:   256,
+			UserDetail:  "age identity",
+		})
+	}
+	return pks
+}
+
+// SecretKeys returns the recipients for the identities in the keyring.
+func (k *Keyring) SecretKeys() []*gpg.SecretKey {
+	var sks []*gpg.SecretKey
+	for _, pk := range k.PublicKeys() {
+		sks = append(sks, &gpg.SecretKey{
+			KeyID:       pk.KeyID,
+			Fingerprint: pk.Fingerprint,
+			CanEncrypt:  pk.CanEncrypt,
+			Trusted:     pk.Trusted,
+			KeyLength:   pk.KeyLength,
+			UserDetail:  pk.UserDetail,
+		})
+	}
+	return sks
+}
+
+// IsRecipient returns true if input string is a valid age recipient.
+func IsRecipient(s string) bool {
+	if s == ScryptRecipient {
+		return true
+	}
+	if !strings.HasPrefix(s, "age1") {
+		return false
+	}
+	_, err := age.ParseX25519Recipient(s)
+	return err == nil
+}
+
+// scryptIdentity prompts for the passphrase only when the encrypted data is
+// encrypted for a scrypt recipient.
+type scryptIdentity struct {
+	passphrase PassphraseFunc
+}
+
+func (s *scryptIdentity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	found := false
+	for _, stanza := range stanzas {
+		if stanza.Type == ScryptRecipient {
+			found = true
+		}
+	}
+	if !found {
+		return nil, age.ErrIncorrectIdentity
+	}
+	passphrase, err := s.passphrase()
+	if err != nil {
+		return nil, xerrors.Errorf("could not get passphrase: %w", err)
+	}
+	id, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, xerrors.Errorf("could not create scrypt identity: %w", err)
+	}
+	return id.Unwrap(stanzas)
+}
@@ -9,18 +9,24 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime/debug"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/bvk/past/agecrypt"
 	"github.com/bvk/past/git"
 	"github.com/bvk/past/gpg"
+	"github.com/bvk/past/pgp"
 	"github.com/bvk/past/store"
 
 	"github.com/spf13/pflag"
+	"golang.org/x/net/publicsuffix"
 	"golang.org/x/xerrors"
 )
 
@@ -53,20 +59,34 @@ func cmdChrome(flags *pflag.FlagSet, args []string) (status error) {
 		return xerrors.Errorf("data directory path be empty: %w", os.ErrInvalid)
 	}
 
-	repo, _ := git.NewDir(dataDir)
-	keyring, _ := gpg.NewKeyring("")
-	pstore, _ := store.New(repo, keyring)
+	// Age encrypted password-files are supported only with identities cause
+	// passphrases cannot be prompted.
+	var ageCrypter store.Crypter
+	if ageFile, err := flags.GetString("age-identity-file"); err == nil {
+		if v, err := agecrypt.NewKeyring(ageFile, nil); err == nil {
+			ageCrypter = v
+		}
+	}
 
-	h := ChromeHandler{
-		dir:     dataDir,
-		repo:    repo,
-		keyring: keyring,
-		pstore:  pstore,
+	s := &ChromeServer{
+		dataDir:    dataDir,
+		ageCrypter: ageCrypter,
+		handlers:   make(map[string]*ChromeHandler),
+	}
+	if _, err := s.handler(""); err != nil {
+		return xerrors.Errorf("could not load keyring and password store: %w", err)
 	}
-	return h.ServeChrome(context.Background(), os.Stdin, os.Stdout)
+	return s.ServeChrome(context.Background(), os.Stdin, os.Stdout)
 }
 
 type ChromeRequest struct {
+	// Store is the name of the password store for the request. Default password
+	// store is used when empty. ListFiles and FindForURL requests can use "*"
+	// to search across all password stores.
+	Store string `json:"store"`
+
+	ListStores *ListStoresRequest `json:"list_stores"`
+
 	CheckStatus *CheckStatusRequest `json:"check_status"`
 
 	CreateKey *CreateKeyRequest `json:"create_key"`
@@ -78,8 +98,12 @@ type ChromeRequest struct {
 	CreateRepo *CreateRepoRequest `json:"create_repo"`
 	ImportRepo *ImportRepoRequest `json:"import_repo"`
 
-	AddRemote  *AddRemoteRequest  `json:"add_remote"`
-	SyncRemote *SyncRemoteRequest `json:"sync_remote"`
+	AddRemote    *AddRemoteRequest    `json:"add_remote"`
+	SyncRemote   *SyncRemoteRequest   `json:"sync_remote"`
+	ListRemotes  *ListRemotesRequest  `json:"list_remotes"`
+	RemoveRemote *RemoveRemoteRequest `json:"remove_remote"`
+	RenameRemote *RenameRemoteRequest `json:"rename_remote"`
+	SetUpstream  *SetUpstreamRequest  `json:"set_upstream"`
 
 	ScanStore       *ScanStoreRequest       `json:"scan_store"`
 	AddRecipient    *AddRecipientRequest    `json:"add_recipient"`
@@ -90,6 +114,9 @@ type ChromeRequest struct {
 	ListFiles  *ListFilesRequest  `json:"list_files"`
 	ViewFile   *ViewFileRequest   `json:"view_file"`
 	DeleteFile *DeleteFileRequest `json:"delete_file"`
+	OTP        *OTPRequest        `json:"otp"`
+
+	FindForURL *FindForURLRequest `json:"find_for_url"`
 }
 
 type ChromeResponse struct {
@@ -97,6 +124,8 @@ type ChromeResponse struct {
 	// on success.
 	Status string `json:"status"`
 
+	ListStores *ListStoresResponse `json:"list_stores"`
+
 	CheckStatus *CheckStatusResponse `json:"check_status"`
 
 	CreateKey *CreateKeyResponse `json:"create_key"`
@@ -108,8 +137,12 @@ type ChromeResponse struct {
 	CreateRepo *CreateRepoResponse `json:"create_repo"`
 	ImportRepo *ImportRepoResponse `json:"import_repo"`
 
-	AddRemote  *AddRemoteResponse  `json:"add_remote"`
-	SyncRemote *SyncRemoteResponse `json:"sync_remote"`
+	AddRemote    *AddRemoteResponse    `json:"add_remote"`
+	SyncRemote   *SyncRemoteResponse   `json:"sync_remote"`
+	ListRemotes  *ListRemotesResponse  `json:"list_remotes"`
+	RemoveRemote *RemoveRemoteResponse `json:"remove_remote"`
+	RenameRemote *RenameRemoteResponse `json:"rename_remote"`
+	SetUpstream  *SetUpstreamResponse  `json:"set_upstream"`
 
 	ScanStore       *ScanStoreResponse       `json:"scan_store"`
 	AddRecipient    *AddRecipientResponse    `json:"add_recipient"`
@@ -120,6 +153,19 @@ type ChromeResponse struct {
 	ListFiles  *ListFilesResponse  `json:"list_files"`
 	ViewFile   *ViewFileResponse   `json:"view_file"`
 	DeleteFile *DeleteFileResponse `json:"delete_file"`
+	OTP        *OTPResponse        `json:"otp"`
+
+	FindForURL *FindForURLResponse `json:"find_for_url"`
+}
+
+type ListStoresRequest struct {
+}
+
+type ListStoresResponse struct {
+	// DataDir is the data directory for the default password store.
+	DataDir string `json:"data_dir"`
+
+	Stores []*StoreProfile `json:"stores"`
 }
 
 type CheckStatusRequest struct {
@@ -136,6 +182,10 @@ type CheckStatusResponse struct {
 	PasswordStoreKeys []string `json:"password_store_keys"`
 
 	Remote string `json:"remote"`
+
+	// ClipboardTimeout is the number of seconds to keep the copied passwords in
+	// the clipboard.
+	ClipboardTimeout int `json:"clipboard_timeout"`
 }
 
 type CreateRepoRequest struct {
@@ -151,6 +201,13 @@ type ImportRepoRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Path     string `json:"path"`
+
+	// Name is the remote name, which is "past-remote" by default.
+	Name string `json:"name"`
+
+	// Branch is the remote branch to import, which is remote's default branch
+	// when empty.
+	Branch string `json:"branch"`
 }
 
 type ImportRepoResponse struct {
@@ -162,6 +219,13 @@ type AddRemoteRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Path     string `json:"path"`
+
+	// Name is the remote name, which is "past-remote" by default.
+	Name string `json:"name"`
+
+	// Upstream when true makes the new remote the upstream for the current
+	// branch. First remote is always made the upstream.
+	Upstream bool `json:"upstream"`
 }
 
 type AddRemoteResponse struct {
@@ -172,6 +236,17 @@ type SyncRemoteRequest struct {
 	Fetch bool `json:"fetch"`
 	Pull  bool `json:"pull"`
 	Push  bool `json:"push"`
+	Sync  bool `json:"sync"`
+
+	// Force when true makes Pull discard the local commits that are not in the
+	// remote and makes Push overwrite the remote commits that are not in the
+	// local repository.
+	Force bool `json:"force"`
+
+	// Remote and Branch select the remote branch, which is the upstream of the
+	// current branch by default.
+	Remote string `json:"remote"`
+	Branch string `json:"branch"`
 }
 
 type SyncRemoteResponse struct {
@@ -179,6 +254,66 @@ type SyncRemoteResponse struct {
 	Remote *git.LogItem `json:"remote"`
 
 	NewerCommit string `json:"newer_commit"`
+
+	RemoteName string `json:"remote_name"`
+	Branch     string `json:"branch"`
+
+	// Ahead and Behind are the number of commits in the local branch that are
+	// not in the remote branch and vice versa.
+	Ahead  int `json:"ahead"`
+	Behind int `json:"behind"`
+}
+
+type ListRemotesRequest struct {
+	// Fetch when true fetches all remotes before comparing them with the
+	// current branch.
+	Fetch bool `json:"fetch"`
+}
+
+type ListRemotesResponse struct {
+	CurrentBranch string   `json:"current_branch"`
+	Branches      []string `json:"branches"`
+
+	Remotes []*RemoteInfo `json:"remotes"`
+}
+
+type RemoteInfo struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Branches []string `json:"branches"`
+
+	// Branch is the remote branch compared with the current branch, which is
+	// the upstream branch for the upstream remote.
+	Branch   string `json:"branch"`
+	Upstream bool   `json:"upstream"`
+
+	Ahead  int `json:"ahead"`
+	Behind int `json:"behind"`
+}
+
+type RemoveRemoteRequest struct {
+	Name string `json:"name"`
+}
+
+type RemoveRemoteResponse struct {
+}
+
+type RenameRemoteRequest struct {
+	Name    string `json:"name"`
+	NewName string `json:"new_name"`
+}
+
+type RenameRemoteResponse struct {
+}
+
+type SetUpstreamRequest struct {
+	Remote string `json:"remote"`
+
+	// Branch is the remote branch, which is the current branch name by default.
+	Branch string `json:"branch"`
+}
+
+type SetUpstreamResponse struct {
 }
 
 type CreateKeyRequest struct {
@@ -261,6 +396,10 @@ type ListFilesRequest struct {
 
 type ListFilesResponse struct {
 	Files []string `json:"files"`
+
+	// Stores holds the store name for each file in Files when files are listed
+	// across all password stores.
+	Stores []string `json:"stores,omitempty"`
 }
 
 type AddFileRequest struct {
@@ -297,6 +436,21 @@ type ViewFileResponse struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Data     string `json:"data"`
+
+	// HasOTP is true when the password-file has an OTP secret.
+	HasOTP bool `json:"has_otp"`
+}
+
+type OTPRequest struct {
+	Filename string `json:"filename"`
+}
+
+type OTPResponse struct {
+	Code string `json:"code"`
+	Type string `json:"type"`
+
+	// Remaining is the number of seconds after which a TOTP code expires.
+	Remaining int `json:"remaining"`
 }
 
 type DeleteFileRequest struct {
@@ -306,35 +460,374 @@ type DeleteFileRequest struct {
 type DeleteFileResponse struct {
 }
 
+type FindForURLRequest struct {
+	URL string `json:"url"`
+
+	// CheckValues when true also matches the `url:` values in the password
+	// files. It requires decrypting all password-files, so it can be slow for
+	// large password stores.
+	CheckValues bool `json:"check_values"`
+}
+
+type FindForURLResponse struct {
+	// Matches holds the matching password-files ordered from the best match to
+	// the worst.
+	Matches []*URLMatch `json:"matches"`
+}
+
+type URLMatch struct {
+	Filename string `json:"filename"`
+
+	// Store is the name of the password store with the password-file when
+	// matches are searched across all password stores.
+	Store string `json:"store,omitempty"`
+
+	// Host is the hostname from the password-file that matched the URL.
+	Host string `json:"host"`
+
+	// Match is one of "host", "domain" or "subdomain", when the password-file
+	// is for the same host, for the registrable domain or for another subdomain
+	// of the registrable domain respectively.
+	Match string `json:"match"`
+
+	// Source is "value" when the match is from an `url:` value in the
+	// password-file and "path" when it is from the password-file path.
+	Source string `json:"source"`
+
+	score int
+}
+
 type ChromeHandler struct {
 	dir     string
 	repo    *git.Dir
 	keyring *gpg.Keyring
 	pstore  *store.PasswordStore
+
+	// native is the OpenPGP keyring for the password stores with the native
+	// backend. It is used instead of the gpg keyring for the password-files.
+	native *pgp.Keyring
+
+	ageCrypter store.Crypter
+
+	// remote is the git remote for sync operations when it is not empty.
+	remote string
+
+	// config holds the defaults from the config files.
+	config *Config
+
+	// urlValuesMap caches the `url:` values in the password-files, so that
+	// they are decrypted only once per session.
+	urlValuesMap map[string][]string
+}
+
+// ChromeServer dispatches the requests to the chrome handlers for the default
+// password store and the named password stores.
+type ChromeServer struct {
+	dataDir    string
+	ageCrypter store.Crypter
+
+	// keyring is the gpg keyring shared by all handlers.
+	keyring *gpg.Keyring
+
+	// handlers holds the chrome handler for each store name, where empty name
+	// is for the default password store. Handlers are created on first use.
+	handlers map[string]*ChromeHandler
+}
+
+// handler returns the chrome handler for a password store.
+func (s *ChromeServer) handler(name string) (*ChromeHandler, error) {
+	if h, ok := s.handlers[name]; ok {
+		return h, nil
+	}
+	if s.keyring == nil {
+		if err := s.refreshKeyring(); err != nil {
+			return nil, err
+		}
+	}
+	h := &ChromeHandler{dir: s.dataDir, keyring: s.keyring, ageCrypter: s.ageCrypter}
+	if len(name) > 0 {
+		profile, err := findStore(name)
+		if err != nil {
+			return nil, err
+		}
+		h.dir = profile.DataDir
+		h.remote = profile.Remote
+		if len(profile.AgeIdentityFile) > 0 {
+			h.ageCrypter = nil
+			if v, err := agecrypt.NewKeyring(profile.AgeIdentityFile, nil); err == nil {
+				h.ageCrypter = v
+			}
+		}
+		if profile.Backend == "native" {
+			files := profile.KeyringFiles
+			if len(files) == 0 {
+				files = pgp.DefaultKeyringFiles()
+			}
+			keyring, err := pgp.NewKeyring(files, nil)
+			if err != nil {
+				return nil, xerrors.Errorf("could not create native key ring for store %q: %w", name, err)
+			}
+			h.native = keyring
+		}
+	}
+	if err := h.refresh(); err != nil {
+		return nil, xerrors.Errorf("could not load keyring and password store %q: %w", name, err)
+	}
+	s.handlers[name] = h
+	return h, nil
+}
+
+// storeNames returns the names of all password stores including the empty
+// name for the default password store.
+func (s *ChromeServer) storeNames() ([]string, error) {
+	profiles, err := loadStores()
+	if err != nil {
+		return nil, xerrors.Errorf("could not load store profiles: %w", err)
+	}
+	names := []string{""}
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	return names, nil
 }
 
-func (c *ChromeHandler) ServeChrome(ctx context.Context, in io.Reader, out io.Writer) (status error) {
+// ServeChrome serves native messages from the input stream till it is closed.
+// Extension can use sendNativeMessage for one request per process or
+// connectNative for a long-lived session with many requests.
+func (s *ChromeServer) ServeChrome(ctx context.Context, in io.Reader, out io.Writer) (status error) {
 	defer func() {
 		if status != nil {
-			log.Printf("error: chrome operation has failed: %w", status)
+			log.Printf("error: chrome operation has failed: %v", status)
 		}
 	}()
 
+	for {
+		reqBuf, err := readMessage(in)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return xerrors.Errorf("could not read input message: %w", err)
+		}
+
+		var resp *ChromeResponse
+		req := new(ChromeRequest)
+		if err := json.Unmarshal(reqBuf, req); err != nil {
+			resp = &ChromeResponse{Status: xerrors.Errorf("could not unmarshal input message: %w", err).Error()}
+		} else {
+			resp = s.serveRequest(ctx, req)
+		}
+
+		if err := writeMessage(out, resp); err != nil {
+			return xerrors.Errorf("could not write response message: %w", err)
+		}
+
+		// Keyring and password store state is reused across the requests, so it
+		// must be reloaded after requests that may have modified them.
+		if !req.isReadOnly() {
+			if err := s.refresh(); err != nil {
+				return xerrors.Errorf("could not refresh keyring and password stores: %w", err)
+			}
+		}
+	}
+}
+
+// refreshKeyring reloads the shared keyring. Keyring is left nil when it is
+// not initialized yet.
+func (s *ChromeServer) refreshKeyring() error {
+	if s.keyring == nil {
+		s.keyring, _ = gpg.NewKeyringWithOptions("", gpgOptions())
+		return nil
+	}
+	if err := s.keyring.Refresh(); err != nil {
+		return xerrors.Errorf("could not refresh keyring: %w", err)
+	}
+	return nil
+}
+
+// refresh reloads the shared keyring once and then the password stores of all
+// handlers. Handlers that cannot be reloaded are removed, so that they are
+// created again on next use and other password stores remain usable.
+func (s *ChromeServer) refresh() error {
+	if err := s.refreshKeyring(); err != nil {
+		return err
+	}
+	for name, h := range s.handlers {
+		h.keyring = s.keyring
+		if err := h.refresh(); err != nil {
+			log.Printf("warning: could not refresh password store %q (ignored): %v", name, err)
+			delete(s.handlers, name)
+		}
+	}
+	return nil
+}
+
+func (s *ChromeServer) serveRequest(ctx context.Context, req *ChromeRequest) *ChromeResponse {
+	if req.ListStores != nil {
+		resp := &ChromeResponse{ListStores: new(ListStoresResponse)}
+		if err := s.doListStores(ctx, req.ListStores, resp.ListStores); err != nil {
+			resp.Status = err.Error()
+		}
+		return resp
+	}
+	if req.Store == AllStores {
+		return s.serveAllStores(ctx, req)
+	}
+	h, err := s.handler(req.Store)
+	if err != nil {
+		return &ChromeResponse{Status: xerrors.Errorf("could not load password store %q: %w", req.Store, err).Error()}
+	}
+	return h.serveRequest(ctx, req)
+}
+
+// serveAllStores serves the search requests across all password stores.
+// Password stores that cannot be loaded are skipped.
+func (s *ChromeServer) serveAllStores(ctx context.Context, req *ChromeRequest) *ChromeResponse {
+	var resp ChromeResponse
+	names, err := s.storeNames()
+	if err != nil {
+		resp.Status = err.Error()
+		return &resp
+	}
+	switch {
+	case req.ListFiles != nil:
+		resp.ListFiles = new(ListFilesResponse)
+	case req.FindForURL != nil:
+		resp.FindForURL = new(FindForURLResponse)
+	default:
+		resp.Status = xerrors.Errorf("request is not supported across all stores: %w", os.ErrInvalid).Error()
+		return &resp
+	}
+
+	for _, name := range names {
+		h, err := s.handler(name)
+		if err != nil {
+			log.Printf("warning: could not load password store %q (ignored): %v", name, err)
+			continue
+		}
+		if h.pstore == nil {
+			continue
+		}
+		switch {
+		case req.ListFiles != nil:
+			r := new(ListFilesResponse)
+			if err := h.doListFiles(ctx, req.ListFiles, r); err != nil {
+				resp.Status = err.Error()
+				return &resp
+			}
+			for _, file := range r.Files {
+				resp.ListFiles.Files = append(resp.ListFiles.Files, file)
+				resp.ListFiles.Stores = append(resp.ListFiles.Stores, name)
+			}
+		case req.FindForURL != nil:
+			r := new(FindForURLResponse)
+			if err := h.doFindForURL(ctx, req.FindForURL, r); err != nil {
+				resp.Status = err.Error()
+				return &resp
+			}
+			for _, m := range r.Matches {
+				m.Store = name
+				resp.FindForURL.Matches = append(resp.FindForURL.Matches, m)
+			}
+		}
+	}
+	if resp.FindForURL != nil {
+		sortURLMatches(resp.FindForURL.Matches)
+	}
+	return &resp
+}
+
+func (s *ChromeServer) doListStores(ctx context.Context, req *ListStoresRequest, resp *ListStoresResponse) error {
+	profiles, err := loadStores()
+	if err != nil {
+		return xerrors.Errorf("could not load store profiles: %w", err)
+	}
+	resp.DataDir = s.dataDir
+	resp.Stores = profiles
+	return nil
+}
+
+// isReadOnly returns true if the request doesn't modify the keyring or the
+// password store.
+func (r *ChromeRequest) isReadOnly() bool {
+	return r.CheckStatus != nil || r.ExportKey != nil || r.ScanStore != nil ||
+		r.ListFiles != nil || r.ViewFile != nil || r.FindForURL != nil ||
+		r.ListRemotes != nil || r.ListStores != nil
+}
+
+// refresh reloads the native keyring and the password store. Password store is
+// left nil when it is not initialized yet. Shared gpg keyring is reloaded by
+// the chrome server.
+func (c *ChromeHandler) refresh() error {
+	if c.repo == nil {
+		c.repo, _ = git.NewDir(c.dir)
+	}
+	if c.native != nil {
+		if err := c.native.Refresh(); err != nil {
+			return xerrors.Errorf("could not refresh native keyring: %w", err)
+		}
+	}
+
+	var crypter store.Crypter
+	if c.native != nil {
+		crypter = c.native
+	} else if c.keyring != nil {
+		crypter = c.keyring
+	}
+
+	// Config file in the password store may change with the remote changes.
+	config, err := loadConfig(c.dir)
+	if err != nil {
+		log.Printf("warning: could not load config (ignored): %v", err)
+		config = new(Config)
+	}
+	c.config = config
+
+	c.pstore = nil
+	c.urlValuesMap = nil
+	if (crypter != nil || c.ageCrypter != nil) && c.repo != nil {
+		if c.pstore, _ = store.New(c.repo, crypter, c.ageCrypter); c.pstore != nil {
+			c.pstore.SetKeys(envKeys())
+			if err := c.pstore.SetSigningKeys(envSigningKeys()); err != nil {
+				log.Printf("could not use the signing keys, so password store is unavailable: %v", err)
+				c.pstore = nil
+			}
+		}
+	}
+	if c.repo != nil {
+		c.config.setupRepo(c.repo, c.pstore)
+	}
+	return nil
+}
+
+func readMessage(in io.Reader) ([]byte, error) {
 	var sizeBytes [4]byte
-	if _, err := io.ReadFull(os.Stdin, sizeBytes[:]); err != nil {
-		return xerrors.Errorf("could not read input message length: %w", err)
+	if _, err := io.ReadFull(in, sizeBytes[:]); err != nil {
+		return nil, err
 	}
 	size := binary.LittleEndian.Uint32(sizeBytes[:])
-	reqBuf := make([]byte, size)
-	if _, err := io.ReadFull(os.Stdin, reqBuf); err != nil {
-		return xerrors.Errorf("could not read input message: %w", err)
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(in, msg); err != nil {
+		return nil, xerrors.Errorf("could not read message of %d bytes: %w", size, err)
 	}
+	return msg, nil
+}
 
-	req := new(ChromeRequest)
-	if err := json.Unmarshal(reqBuf, req); err != nil {
-		return xerrors.Errorf("could not unmarshal input message: %w", err)
+func writeMessage(out io.Writer, resp *ChromeResponse) error {
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return xerrors.Errorf("could not marshal response (%T) to json: %w", resp, err)
+	}
+	if err := binary.Write(out, binary.LittleEndian, uint32(len(respBytes))); err != nil {
+		return xerrors.Errorf("could not write response size: %w", err)
+	}
+	if _, err := out.Write(respBytes); err != nil {
+		return xerrors.Errorf("could not write response bytes: %w", err)
 	}
+	return nil
+}
 
+func (c *ChromeHandler) serveRequest(ctx context.Context, req *ChromeRequest) *ChromeResponse {
 	var resp ChromeResponse
 	switch {
 	case req.CheckStatus != nil:
@@ -387,6 +880,26 @@ func (c *ChromeHandler) ServeChrome(ctx context.Context, in io.Reader, out io.Wr
 		if err := c.doSyncRemote(ctx, req.SyncRemote, resp.SyncRemote); err != nil {
 			resp.Status = err.Error()
 		}
+	case req.ListRemotes != nil:
+		resp.ListRemotes = new(ListRemotesResponse)
+		if err := c.doListRemotes(ctx, req.ListRemotes, resp.ListRemotes); err != nil {
+			resp.Status = err.Error()
+		}
+	case req.RemoveRemote != nil:
+		resp.RemoveRemote = new(RemoveRemoteResponse)
+		if err := c.doRemoveRemote(ctx, req.RemoveRemote, resp.RemoveRemote); err != nil {
+			resp.Status = err.Error()
+		}
+	case req.RenameRemote != nil:
+		resp.RenameRemote = new(RenameRemoteResponse)
+		if err := c.doRenameRemote(ctx, req.RenameRemote, resp.RenameRemote); err != nil {
+			resp.Status = err.Error()
+		}
+	case req.SetUpstream != nil:
+		resp.SetUpstream = new(SetUpstreamResponse)
+		if err := c.doSetUpstream(ctx, req.SetUpstream, resp.SetUpstream); err != nil {
+			resp.Status = err.Error()
+		}
 	case req.ScanStore != nil:
 		resp.ScanStore = new(ScanStoreResponse)
 		if err := c.doScanStore(ctx, req.ScanStore, resp.ScanStore); err != nil {
@@ -427,21 +940,21 @@ func (c *ChromeHandler) ServeChrome(ctx context.Context, in io.Reader, out io.Wr
 		if err := c.doDeleteFile(ctx, req.DeleteFile, resp.DeleteFile); err != nil {
 			resp.Status = err.Error()
 		}
+	case req.OTP != nil:
+		resp.OTP = new(OTPResponse)
+		if err := c.doOTP(ctx, req.OTP, resp.OTP); err != nil {
+			resp.Status = err.Error()
+		}
+	case req.FindForURL != nil:
+		resp.FindForURL = new(FindForURLResponse)
+		if err := c.doFindForURL(ctx, req.FindForURL, resp.FindForURL); err != nil {
+			resp.Status = err.Error()
+		}
 	default:
 		resp.Status = xerrors.Errorf("unknown or invalid request: %w", os.ErrInvalid).Error()
 	}
 
-	respBytes, err := json.Marshal(&resp)
-	if err != nil {
-		return xerrors.Errorf("could not marshal response (%T) to json: %w", resp, err)
-	}
-	if err := binary.Write(os.Stdout, binary.LittleEndian, uint32(len(respBytes))); err != nil {
-		return xerrors.Errorf("could not write response size: %w", err)
-	}
-	if _, err := os.Stdout.Write(respBytes); err != nil {
-		return xerrors.Errorf("could not write response bytes: %w", err)
-	}
-	return nil
+	return &resp
 }
 
 func GetPublicKeysData(ring *gpg.Keyring) []*store.PublicKeyData {
@@ -471,12 +984,15 @@ func (c *ChromeHandler) doCheckStatus(ctx context.Context, req *CheckStatusReque
 	if p, err := exec.LookPath("gpg"); err == nil {
 		resp.GPGPath = p
 	}
+	resp.ClipboardTimeout = c.config.clipboardTimeout()
 	if c.pstore != nil {
 		resp.PasswordStoreKeys, _ = c.pstore.FileKeys(".")
 	}
 	if c.repo != nil {
-		if addr, err := c.repo.GetRemoteURL("past-remote"); err == nil {
-			resp.Remote = addr
+		if remote, _, err := c.syncTarget("", ""); err == nil {
+			if addr, err := c.repo.GetRemoteURL(remote); err == nil {
+				resp.Remote = addr
+			}
 		}
 	}
 	if c.keyring != nil {
@@ -595,7 +1111,7 @@ func (c *ChromeHandler) doCreateRepo(ctx context.Context, req *CreateRepoRequest
 		}
 		c.repo = repo
 	}
-	pstore, err := store.Create(c.repo, c.keyring, req.Fingerprints)
+	pstore, err := store.Create(c.repo, c.keyring, req.Fingerprints, envSigningKeys())
 	if err != nil {
 		return err
 	}
@@ -615,7 +1131,10 @@ func (c *ChromeHandler) doImportRepo(ctx context.Context, req *ImportRepoRequest
 	reqPath := filepath.Clean(filepath.Join("/", req.Path))
 
 	remoteURL := ""
-	remoteName := "past-remote"
+	remoteName := c.config.remoteName()
+	if len(req.Name) > 0 {
+		remoteName = req.Name
+	}
 	switch req.Protocol {
 	case "ssh":
 		remoteURL = fmt.Sprintf("ssh://%s@%s%s", reqUsername, req.Hostname, reqPath)
@@ -667,8 +1186,19 @@ func (c *ChromeHandler) doImportRepo(ctx context.Context, req *ImportRepoRequest
 		return xerrors.Errorf("could not git fetch from remotes: %w", err)
 	}
 
-	if err := repo.Reset(fmt.Sprintf("%s/master", remoteName)); err != nil {
-		return xerrors.Errorf("could not reset working copy to remote master: %w", err)
+	branch := req.Branch
+	if len(branch) == 0 {
+		v, err := repo.DefaultBranch(remoteName)
+		if err != nil {
+			return xerrors.Errorf("could not determine the branch to import: %w", err)
+		}
+		branch = v
+	}
+	if err := repo.Checkout(branch, remoteName+"/"+branch); err != nil {
+		return xerrors.Errorf("could not checkout remote branch %q: %w", branch, err)
+	}
+	if err := repo.SetUpstream(remoteName, branch); err != nil {
+		return xerrors.Errorf("could not set upstream to remote branch %q: %w", branch, err)
 	}
 
 	// TODO: Check that at least one file can be decrypted with the local keyring.
@@ -685,7 +1215,10 @@ func (c *ChromeHandler) doAddRemote(ctx context.Context, req *AddRemoteRequest,
 	reqPath := filepath.Clean(filepath.Join("/", req.Path))
 
 	remoteURL := ""
-	remoteName := "past-remote"
+	remoteName := c.config.remoteName()
+	if len(req.Name) > 0 {
+		remoteName = req.Name
+	}
 	switch req.Protocol {
 	case "ssh":
 		remoteURL = fmt.Sprintf("ssh://%s@%s%s", reqUsername, req.Hostname, reqPath)
@@ -740,7 +1273,28 @@ func (c *ChromeHandler) doAddRemote(ctx context.Context, req *AddRemoteRequest,
 		}()
 	}
 
-	syncReq := &SyncRemoteRequest{Fetch: true}
+	if err := c.repo.Fetch(remoteName); err != nil {
+		return xerrors.Errorf("could not fetch from remote %q: %w", remoteName, err)
+	}
+
+	// New remote is made the upstream when requested or when current branch
+	// doesn't have an upstream yet.
+	if _, _, err := c.repo.Upstream(); err != nil || req.Upstream {
+		branch, err := c.repo.CurrentBranch()
+		if err != nil {
+			return xerrors.Errorf("could not determine current branch: %w", err)
+		}
+		if !c.repo.HasRef(remoteName + "/" + branch) {
+			if v, err := c.repo.DefaultBranch(remoteName); err == nil {
+				branch = v
+			}
+		}
+		if err := c.repo.SetUpstream(remoteName, branch); err != nil {
+			return xerrors.Errorf("could not set upstream to remote %q: %w", remoteName, err)
+		}
+	}
+
+	syncReq := &SyncRemoteRequest{Remote: remoteName}
 	syncResp := new(SyncRemoteResponse)
 	if err := c.doSyncRemote(ctx, syncReq, syncResp); err != nil {
 		return xerrors.Errorf("could not determine the diff with remote %q: %w", remoteName, err)
@@ -749,47 +1303,212 @@ func (c *ChromeHandler) doAddRemote(ctx context.Context, req *AddRemoteRequest,
 	return nil
 }
 
+// defaultRemoteName is the remote name used when a remote name is not given.
+const defaultRemoteName = "past-remote"
+
+// syncTarget returns the remote and the remote branch for the sync operations.
+// Remote from the store profile or the upstream of the current branch is used
+// by default. When there is no upstream, the only remote or the default remote
+// is used with the current branch name.
+func (c *ChromeHandler) syncTarget(remote, branch string) (string, string, error) {
+	if len(remote) == 0 {
+		remote = c.remote
+	}
+	upRemote, upBranch, err := c.repo.Upstream()
+	if err == nil {
+		if len(remote) == 0 {
+			remote = upRemote
+		}
+		if len(branch) == 0 && remote == upRemote {
+			branch = upBranch
+		}
+	}
+	if len(remote) == 0 {
+		remotes, err := c.repo.Remotes()
+		if err != nil {
+			return "", "", xerrors.Errorf("could not list remotes: %w", err)
+		}
+		remote = c.config.remoteName()
+		if len(remotes) == 1 {
+			remote = remotes[0]
+		}
+	}
+	if len(branch) == 0 {
+		current, err := c.repo.CurrentBranch()
+		if err != nil {
+			return "", "", xerrors.Errorf("could not determine current branch: %w", err)
+		}
+		branch = current
+	}
+	return remote, branch, nil
+}
+
 func (c *ChromeHandler) doSyncRemote(ctx context.Context, req *SyncRemoteRequest, resp *SyncRemoteResponse) error {
 	if c.repo == nil {
 		return xerrors.Errorf("git repository is not initialized: %w", os.ErrInvalid)
 	}
-	remoteName := "past-remote"
-	remoteMaster := "past-remote/master"
+	remoteName, branch, err := c.syncTarget(req.Remote, req.Branch)
+	if err != nil {
+		return xerrors.Errorf("could not determine remote branch: %w", err)
+	}
+	remoteBranch := remoteName + "/" + branch
 	switch {
 	case req.Fetch:
 		if err := c.repo.Fetch(remoteName); err != nil {
 			return xerrors.Errorf("could not fetch from remote: %w", err)
 		}
+	case req.Push && req.Force:
+		if err := c.repo.PushOverwrite(remoteName, branch); err != nil {
+			return xerrors.Errorf("could not push to %q: %w", remoteBranch, err)
+		}
 	case req.Push:
-		if err := c.repo.PushOverwrite(remoteName, "master"); err != nil {
-			return xerrors.Errorf("could not push to %q: %w", remoteMaster, err)
+		if err := c.repo.Push(remoteName, branch); err != nil {
+			return xerrors.Errorf("could not push to %q (sync or force push): %w", remoteBranch, err)
+		}
+	case req.Pull && req.Force:
+		if err := c.repo.Fetch(remoteName); err != nil {
+			return xerrors.Errorf("could not fetch from remote: %w", err)
+		}
+		if err := c.repo.VerifyIncoming(remoteBranch); err != nil {
+			return err
+		}
+		if err := c.repo.Reset(remoteBranch); err != nil {
+			return xerrors.Errorf("could not pull from %q: %w", remoteBranch, err)
 		}
 	case req.Pull:
-		if err := c.repo.Reset(remoteMaster); err != nil {
-			return xerrors.Errorf("could not pull from %q: %w", remoteMaster, err)
+		if err := c.repo.Pull(remoteName, branch); err != nil {
+			return xerrors.Errorf("could not pull from %q: %w", remoteBranch, err)
+		}
+	case req.Sync:
+		if err := c.repo.Sync(remoteName, branch); err != nil {
+			return xerrors.Errorf("could not sync with %q: %w", remoteBranch, err)
 		}
 	}
+	resp.RemoteName = remoteName
+	resp.Branch = branch
+
 	head, err := c.repo.GetLogItem("HEAD")
 	if err != nil {
 		return xerrors.Errorf("could not get head log tip: %w", err)
 	}
-	remote, err := c.repo.GetLogItem(remoteMaster)
+	remote, err := c.repo.GetLogItem(remoteBranch)
 	if err != nil {
-		return xerrors.Errorf("could not get %q log tip: %w", remoteMaster, err)
+		return xerrors.Errorf("could not get %q log tip: %w", remoteBranch, err)
 	}
 	resp.Head = head
 	resp.Remote = remote
 
 	if head.Commit != remote.Commit {
 		if yes, _ := c.repo.IsAncestor(head.Commit, remote.Commit); yes {
-			// Remote master has more commits than head.
+			// Remote branch has more commits than head.
 			resp.NewerCommit = remote.Commit
 		} else if yes, _ := c.repo.IsAncestor(remote.Commit, head.Commit); yes {
-			// Head has more commits than remote master.
+			// Head has more commits than remote branch.
 			resp.NewerCommit = head.Commit
 		}
 	}
+	if resp.Ahead, resp.Behind, err = c.repo.AheadBehind("HEAD", remoteBranch); err != nil {
+		return xerrors.Errorf("could not compare head with %q: %w", remoteBranch, err)
+	}
+	return nil
+}
+
+func (c *ChromeHandler) doListRemotes(ctx context.Context, req *ListRemotesRequest, resp *ListRemotesResponse) error {
+	if c.repo == nil {
+		return xerrors.Errorf("git repository is not initialized: %w", os.ErrInvalid)
+	}
+	if req.Fetch {
+		if err := c.repo.FetchAll(); err != nil {
+			return xerrors.Errorf("could not fetch remotes: %w", err)
+		}
+	}
+
+	current, err := c.repo.CurrentBranch()
+	if err != nil {
+		return xerrors.Errorf("could not determine current branch: %w", err)
+	}
+	branches, err := c.repo.Branches()
+	if err != nil {
+		return xerrors.Errorf("could not list branches: %w", err)
+	}
+	resp.CurrentBranch = current
+	resp.Branches = branches
+
+	upRemote, upBranch, _ := c.repo.Upstream()
+	remotes, err := c.repo.Remotes()
+	if err != nil {
+		return xerrors.Errorf("could not list remotes: %w", err)
+	}
+	for _, remote := range remotes {
+		info := &RemoteInfo{Name: remote}
+		if info.URL, err = c.repo.GetRemoteURL(remote); err != nil {
+			return xerrors.Errorf("could not get url for remote %q: %w", remote, err)
+		}
+		if info.Branches, err = c.repo.RemoteBranches(remote); err != nil {
+			return xerrors.Errorf("could not list branches for remote %q: %w", remote, err)
+		}
+
+		info.Branch = current
+		if remote == upRemote {
+			info.Branch, info.Upstream = upBranch, true
+		}
+		if c.repo.HasRef(remote + "/" + info.Branch) {
+			if info.Ahead, info.Behind, err = c.repo.AheadBehind("HEAD", remote+"/"+info.Branch); err != nil {
+				return xerrors.Errorf("could not compare head with remote %q: %w", remote, err)
+			}
+		}
+		resp.Remotes = append(resp.Remotes, info)
+	}
+	return nil
+}
+
+func (c *ChromeHandler) doRemoveRemote(ctx context.Context, req *RemoveRemoteRequest, resp *RemoveRemoteResponse) error {
+	if c.repo == nil {
+		return xerrors.Errorf("git repository is not initialized: %w", os.ErrInvalid)
+	}
+	if err := c.repo.RemoveRemote(req.Name); err != nil {
+		return xerrors.Errorf("could not remove remote %q: %w", req.Name, err)
+	}
+	return nil
+}
+
+func (c *ChromeHandler) doRenameRemote(ctx context.Context, req *RenameRemoteRequest, resp *RenameRemoteResponse) error {
+	if c.repo == nil {
+		return xerrors.Errorf("git repository is not initialized: %w", os.ErrInvalid)
+	}
+	if len(req.NewName) == 0 {
+		return xerrors.Errorf("new remote name cannot be empty: %w", os.ErrInvalid)
+	}
+	if err := c.repo.RenameRemote(req.Name, req.NewName); err != nil {
+		return xerrors.Errorf("could not rename remote %q: %w", req.Name, err)
+	}
+	return nil
+}
 
+func (c *ChromeHandler) doSetUpstream(ctx context.Context, req *SetUpstreamRequest, resp *SetUpstreamResponse) error {
+	if c.repo == nil {
+		return xerrors.Errorf("git repository is not initialized: %w", os.ErrInvalid)
+	}
+	remotes, err := c.repo.Remotes()
+	if err != nil {
+		return xerrors.Errorf("could not list remotes: %w", err)
+	}
+	found := false
+	for _, remote := range remotes {
+		found = found || remote == req.Remote
+	}
+	if !found {
+		return xerrors.Errorf("remote %q doesn't exist: %w", req.Remote, os.ErrNotExist)
+	}
+	branch := req.Branch
+	if len(branch) == 0 {
+		if branch, err = c.repo.CurrentBranch(); err != nil {
+			return xerrors.Errorf("could not determine current branch: %w", err)
+		}
+	}
+	if err := c.repo.SetUpstream(req.Remote, branch); err != nil {
+		return xerrors.Errorf("could not set upstream to %s/%s: %w", req.Remote, branch, err)
+	}
 	return nil
 }
 
@@ -911,6 +1630,9 @@ func (c *ChromeHandler) doAddFile(ctx context.Context, req *AddFileRequest, resp
 	}
 
 	data := store.Format(req.Password, vs.Bytes())
+	if err := checkPasswordRules(data); err != nil {
+		return xerrors.Errorf("could not add new file: %w", err)
+	}
 	if err := c.pstore.CreateFile(req.Filename, data, os.FileMode(0644)); err != nil {
 		return xerrors.Errorf("could not add new file: %w", err)
 	}
@@ -933,6 +1655,9 @@ func (c *ChromeHandler) doEditFile(ctx context.Context, req *EditFileRequest, re
 	}
 
 	data := store.Format(req.Password, vs.Bytes())
+	if err := checkPasswordRules(data); err != nil {
+		return xerrors.Errorf("could not update file %q: %w", req.Filename, err)
+	}
 	if len(req.OrigFile) > 0 && req.OrigFile != req.Filename {
 		if err := c.pstore.ReplaceFile(req.OrigFile, req.Filename, data); err != nil {
 			return xerrors.Errorf("could not replace file %q: %w", req.OrigFile, err)
@@ -950,16 +1675,11 @@ func (c *ChromeHandler) doListFiles(ctx context.Context, req *ListFilesRequest,
 		return xerrors.Errorf("password store is unavailable to list files (%+v): %w", *c, os.ErrInvalid)
 	}
 
-	files, err := c.repo.ListFiles()
+	files, err := c.pstore.ListFiles()
 	if err != nil {
-		return xerrors.Errorf("could not list files in the git directory: %w", err)
-	}
-
-	for _, file := range files {
-		if strings.HasSuffix(file, ".gpg") {
-			resp.Files = append(resp.Files, strings.TrimSuffix(file, ".gpg"))
-		}
+		return xerrors.Errorf("could not list files in the password store: %w", err)
 	}
+	resp.Files = files
 	return nil
 }
 
@@ -968,14 +1688,9 @@ func (c *ChromeHandler) doViewFile(ctx context.Context, req *ViewFileRequest, re
 		return xerrors.Errorf("password store is unavailable to view file: %w", os.ErrInvalid)
 	}
 
-	file := filepath.Join("./", req.Filename+".gpg")
-	encrypted, err := c.repo.ReadFile(file)
-	if err != nil {
-		return xerrors.Errorf("could not get login entry with name %q: %w", req.Filename, err)
-	}
-	decrypted, err := c.keyring.Decrypt(encrypted)
+	decrypted, err := c.pstore.ReadFile(req.Filename)
 	if err != nil {
-		return xerrors.Errorf("could not decrypt login entry %q: %w", req.Filename, err)
+		return xerrors.Errorf("could not read login entry %q: %w", req.Filename, err)
 	}
 	password, data := store.Parse(decrypted)
 	values := store.NewValues(data)
@@ -1002,6 +1717,24 @@ func (c *ChromeHandler) doViewFile(ctx context.Context, req *ViewFileRequest, re
 	resp.Username = username
 	resp.Password = password
 	resp.Filename = req.Filename
+	if key, _ := getOTPKey(values, req.Filename); key != nil {
+		resp.HasOTP = true
+	}
+	return nil
+}
+
+func (c *ChromeHandler) doOTP(ctx context.Context, req *OTPRequest, resp *OTPResponse) error {
+	if c.pstore == nil {
+		return xerrors.Errorf("password store is unavailable to compute otp: %w", os.ErrInvalid)
+	}
+	now := time.Now()
+	key, code, err := nextOTP(c.pstore, req.Filename, now)
+	if err != nil {
+		return err
+	}
+	resp.Code = code
+	resp.Type = key.Type
+	resp.Remaining = int(key.Remaining(now) / time.Second)
 	return nil
 }
 
@@ -1010,9 +1743,137 @@ func (c *ChromeHandler) doDeleteFile(ctx context.Context, req *DeleteFileRequest
 		return xerrors.Errorf("password store is unavailable to delete file: %w", os.ErrInvalid)
 	}
 
-	file := filepath.Clean(filepath.Join("./", req.File+".gpg"))
-	if err := c.repo.RemoveFile(file); err != nil {
+	file := c.pstore.EntryFile(req.File)
+	if err := c.pstore.Remove(file); err != nil {
 		return xerrors.Errorf("could not remove file %q: %w", file, err)
 	}
 	return nil
 }
+
+func (c *ChromeHandler) doFindForURL(ctx context.Context, req *FindForURLRequest, resp *FindForURLResponse) error {
+	if c.pstore == nil {
+		return xerrors.Errorf("password store is unavailable to find files: %w", os.ErrInvalid)
+	}
+
+	host, err := urlHostname(req.URL)
+	if err != nil {
+		return xerrors.Errorf("could not parse url %q: %w", req.URL, err)
+	}
+
+	files, err := c.pstore.ListFiles()
+	if err != nil {
+		return xerrors.Errorf("could not list files in the password store: %w", err)
+	}
+
+	if req.CheckValues && c.urlValuesMap == nil {
+		urlValuesMap := make(map[string][]string)
+		for _, file := range files {
+			decrypted, err := c.pstore.ReadFile(file)
+			if err != nil {
+				log.Printf("warning: could not read password-file %q (ignored): %v", file, err)
+				continue
+			}
+			_, data := store.Parse(decrypted)
+			urlValuesMap[file] = store.GetURLs(store.NewValues(data))
+		}
+		c.urlValuesMap = urlValuesMap
+	}
+
+	for _, file := range files {
+		var best *URLMatch
+		for _, value := range c.urlValuesMap[file] {
+			vhost, err := urlHostname(value)
+			if err != nil {
+				continue
+			}
+			if m := matchHost(host, vhost); m != nil && (best == nil || m.score > best.score) {
+				m.Source = "value"
+				best = m
+			}
+		}
+		// Password-file paths in `site.com/user` format or with any other path
+		// component that looks like a hostname are also considered.
+		for _, elem := range strings.Split(file, "/") {
+			if !strings.ContainsRune(elem, '.') {
+				continue
+			}
+			if m := matchHost(host, strings.ToLower(elem)); m != nil && (best == nil || m.score > best.score) {
+				m.Source = "path"
+				best = m
+			}
+		}
+		if best != nil {
+			best.Filename = file
+			resp.Matches = append(resp.Matches, best)
+		}
+	}
+
+	sortURLMatches(resp.Matches)
+	return nil
+}
+
+// sortURLMatches orders the matches from the best match to the worst. Matches
+// from the `url:` values are preferred over the matches from the file paths
+// with the same score.
+func sortURLMatches(matches []*URLMatch) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.Source != b.Source {
+			return a.Source == "value"
+		}
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Store < b.Store
+	})
+}
+
+// urlHostname returns the lowercase hostname from an url. Scheme is optional
+// in the input, so that `url:` values like `site.com/login` are also accepted.
+func urlHostname(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if len(host) == 0 {
+		return "", xerrors.Errorf("url has no hostname: %w", os.ErrInvalid)
+	}
+	return host, nil
+}
+
+// registrableDomain returns the eTLD+1 for a hostname. Hostname itself is
+// returned when it doesn't have an eTLD+1, like IP addresses and localhost.
+func registrableDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// matchHost returns the match between a page hostname and the hostname from a
+// password-file or nil if they do not match.
+func matchHost(pageHost, fileHost string) *URLMatch {
+	if pageHost == fileHost {
+		return &URLMatch{Host: fileHost, Match: "host", score: 3}
+	}
+	pageDomain := registrableDomain(pageHost)
+	if pageDomain != registrableDomain(fileHost) {
+		return nil
+	}
+	if strings.HasSuffix(pageHost, "."+fileHost) {
+		return &URLMatch{Host: fileHost, Match: "domain", score: 2}
+	}
+	return &URLMatch{Host: fileHost, Match: "subdomain", score: 1}
+}
@@ -0,0 +1,302 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/bvk/past/git"
+	"github.com/bvk/past/store"
+
+	"github.com/pelletier/go-toml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/xerrors"
+)
+
+// Config holds the defaults for the command-line flags and the browser
+// extension. It is read from the ~/.config/past/config.toml file and from the
+// .past.toml file at the top of the password store, which is committed along
+// with the password-files, so that teams can share the defaults. Values from
+// the user's config file override the values from the password store, which
+// can only tighten the limits like the minimum password length.
+//
+// Fields are pointers so that only the values defined in a config file
+// override the other values.
+type Config struct {
+	Generate  GenerateConfig  `toml:"generate"`
+	Edit      EditConfig      `toml:"edit"`
+	Extension ExtensionConfig `toml:"extension"`
+	Git       GitConfig       `toml:"git"`
+}
+
+type GenerateConfig struct {
+	// Length is the default number of characters in the generated passwords.
+	Length *uint `toml:"length"`
+
+	// MinLength is the minimum number of characters allowed in the generated
+	// passwords.
+	MinLength *uint `toml:"min_length"`
+
+	// Symbols is the default set of symbols in the generated passwords.
+	Symbols *string `toml:"symbols"`
+}
+
+type EditConfig struct {
+	Editor *string `toml:"editor"`
+}
+
+type ExtensionConfig struct {
+	// ClipboardTimeout is the number of seconds after which the passwords
+	// copied by the extension are cleared from the clipboard.
+	ClipboardTimeout *int `toml:"clipboard_timeout"`
+}
+
+type GitConfig struct {
+	// Remote is the default name for the new git remotes.
+	Remote *string `toml:"remote"`
+
+	// SignCommits when true signs all commits with the SigningKey or with the
+	// default key of the user, when SigningKey is empty.
+	SignCommits *bool   `toml:"sign_commits"`
+	SigningKey  *string `toml:"signing_key"`
+
+	// VerifyCommits when true rejects the commits from the remotes that are
+	// not signed by a key from the .gpg-id files.
+	VerifyCommits *bool `toml:"verify_commits"`
+}
+
+// configFile returns the path to the user's config file.
+func configFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".config/past/config.toml")
+}
+
+// loadConfig returns the config from the user's config file merged with the
+// .past.toml file in the data directory, if any. Missing config files are not
+// an error.
+func loadConfig(dataDir string) (*Config, error) {
+	config := new(Config)
+	if err := config.readFile(configFile()); err != nil {
+		return nil, err
+	}
+	if len(dataDir) > 0 {
+		if err := config.readRepoFile(filepath.Join(dataDir, ".past.toml")); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// parseConfigFile returns the config from a config file. It returns nil if
+// the config file doesn't exist.
+func parseConfigFile(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, xerrors.Errorf("could not read config file %q: %w", file, err)
+	}
+	v := new(Config)
+	if err := toml.Unmarshal(data, v); err != nil {
+		return nil, xerrors.Errorf("could not parse config file %q: %w", file, err)
+	}
+	return v, nil
+}
+
+// readFile overrides the config with the values defined in a config file.
+func (c *Config) readFile(file string) error {
+	v, err := parseConfigFile(file)
+	if err != nil || v == nil {
+		return err
+	}
+
+	if v.Generate.Length != nil {
+		c.Generate.Length = v.Generate.Length
+	}
+	if v.Generate.MinLength != nil {
+		c.Generate.MinLength = v.Generate.MinLength
+	}
+	if v.Generate.Symbols != nil {
+		c.Generate.Symbols = v.Generate.Symbols
+	}
+	if v.Edit.Editor != nil {
+		c.Edit.Editor = v.Edit.Editor
+	}
+	if v.Extension.ClipboardTimeout != nil {
+		c.Extension.ClipboardTimeout = v.Extension.ClipboardTimeout
+	}
+	if v.Git.Remote != nil {
+		c.Git.Remote = v.Git.Remote
+	}
+	if v.Git.SignCommits != nil {
+		c.Git.SignCommits = v.Git.SignCommits
+	}
+	if v.Git.SigningKey != nil {
+		c.Git.SigningKey = v.Git.SigningKey
+	}
+	if v.Git.VerifyCommits != nil {
+		c.Git.VerifyCommits = v.Git.VerifyCommits
+	}
+	return nil
+}
+
+// readRepoFile merges the values from a password store's config file, which
+// can be changed by anyone who can push to the repository. Its values are
+// used only when they are not defined in the user's config file and its limits
+// and security settings can only be tightened. Length is used only when it is
+// longer than the user's length and is applied only when it is longer than the
+// effective --length value. Editor and symbols are never read from it, because
+// editor is executed with the decrypted password-files and symbols can weaken
+// the generated passwords.
+func (c *Config) readRepoFile(file string) error {
+	v, err := parseConfigFile(file)
+	if err != nil || v == nil {
+		return err
+	}
+
+	if length := v.Generate.Length; length != nil {
+		if c.Generate.Length == nil || *length > *c.Generate.Length {
+			c.Generate.Length = length
+		}
+	}
+	if min := v.Generate.MinLength; min != nil {
+		if c.Generate.MinLength == nil || *min > *c.Generate.MinLength {
+			c.Generate.MinLength = min
+		}
+	}
+	if timeout := v.Extension.ClipboardTimeout; timeout != nil && *timeout > 0 {
+		if c.Extension.ClipboardTimeout == nil || *timeout < *c.Extension.ClipboardTimeout {
+			c.Extension.ClipboardTimeout = timeout
+		}
+	}
+	if c.Git.Remote == nil {
+		c.Git.Remote = v.Git.Remote
+	}
+
+	// Signing and verifying the commits can only be turned on by the password
+	// store, so that a single commit cannot disable the verification. Signing
+	// key is always chosen by the user.
+	if v.Git.SignCommits != nil && *v.Git.SignCommits {
+		c.Git.SignCommits = v.Git.SignCommits
+	}
+	if v.Git.VerifyCommits != nil && *v.Git.VerifyCommits {
+		c.Git.VerifyCommits = v.Git.VerifyCommits
+	}
+	return nil
+}
+
+// flagValues returns the config values for the flags of a subcommand.
+func (c *Config) flagValues(subcmd string) map[string]string {
+	values := make(map[string]string)
+	switch subcmd {
+	case "generate":
+		if c.Generate.Length != nil {
+			values["length"] = fmt.Sprintf("%d", *c.Generate.Length)
+		}
+		if c.Generate.Symbols != nil {
+			values["symbols"] = *c.Generate.Symbols
+		}
+	case "edit":
+		if c.Edit.Editor != nil {
+			values["editor"] = *c.Edit.Editor
+		}
+	}
+	return values
+}
+
+// presetFlags holds the flags with the defaults from the user's config file or
+// from the environment variables, which are not overridden by the password
+// store's config file.
+var presetFlags = make(map[*pflag.Flag]bool)
+
+// setFlagDefaults replaces the default values for the subcommand flags with
+// the config values. It must be called before the flags are parsed, so that
+// the flags given on the command-line take precedence.
+func setFlagDefaults(root *cobra.Command, config *Config) error {
+	for _, cmd := range root.Commands() {
+		flags := cmd.Flags()
+		for name, value := range config.flagValues(cmd.Name()) {
+			flag := flags.Lookup(name)
+			if flag == nil {
+				continue
+			}
+			if err := flag.Value.Set(value); err != nil {
+				return xerrors.Errorf("could not set --%s default to %q: %w", name, value, err)
+			}
+			flag.DefValue = flag.Value.String()
+			presetFlags[flag] = true
+		}
+	}
+	return nil
+}
+
+// applyConfig updates the flags that are not given on the command-line and
+// are not set from the user's config file or the environment variables with
+// the config values. Length is updated only when the config value is longer.
+func applyConfig(cmd *cobra.Command, config *Config) error {
+	flags := cmd.Flags()
+	for name, value := range config.flagValues(cmd.Name()) {
+		flag := flags.Lookup(name)
+		if flag == nil || flags.Changed(name) {
+			continue
+		}
+		// Length from the password store can only make the passwords longer
+		// than the length from the user's config, the environment variables or
+		// the flag default.
+		if name == "length" {
+			current, err := strconv.Atoi(flag.Value.String())
+			if n, _ := strconv.Atoi(value); err == nil && n <= current {
+				continue
+			}
+		} else if presetFlags[flag] {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return xerrors.Errorf("could not set --%s value to %q: %w", name, value, err)
+		}
+	}
+	return nil
+}
+
+// clipboardTimeout returns the clipboard timeout in seconds for the browser
+// extension.
+func (c *Config) clipboardTimeout() int {
+	if c.Extension.ClipboardTimeout != nil {
+		return *c.Extension.ClipboardTimeout
+	}
+	return 10
+}
+
+// remoteName returns the default name for the new git remotes.
+func (c *Config) remoteName() string {
+	if c.Git.Remote != nil && len(*c.Git.Remote) > 0 {
+		return *c.Git.Remote
+	}
+	return defaultRemoteName
+}
+
+// setupRepo enables signing the commits and verifying the commits from the
+// remotes in the git repository of a password store as configured.
+func (c *Config) setupRepo(repo *git.Dir, ps *store.PasswordStore) {
+	var key string
+	if c.Git.SigningKey != nil {
+		key = *c.Git.SigningKey
+	}
+	repo.SetSignCommits(c.Git.SignCommits != nil && *c.Git.SignCommits, key)
+
+	switch {
+	case c.Git.VerifyCommits == nil || !*c.Git.VerifyCommits:
+		repo.SetVerifier(nil)
+	case ps == nil:
+		repo.SetVerifier(func(from, to string) error {
+			return xerrors.Errorf("password store is required to verify commits: %w", os.ErrInvalid)
+		})
+	default:
+		repo.SetVerifier(ps.VerifyCommits)
+	}
+}
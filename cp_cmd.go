@@ -0,0 +1,42 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var cpCmd = &cobra.Command{
+	Use:   "cp [flags] <old-path> <new-path>",
+	Short: "Copies a password-file or a directory, re-encrypting as necessary.",
+	RunE:  cmdCp,
+}
+
+func init() {
+	flags := cpCmd.Flags()
+	flags.BoolP("force", "f", false, "When true, existing files at the destination are overwritten.")
+}
+
+func cmdCp(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+
+	if len(args) != 2 {
+		return xerrors.Errorf("old and new path arguments are required: %w", os.ErrInvalid)
+	}
+	force, err := flags.GetBool("force")
+	if err != nil {
+		return xerrors.Errorf("could not get --force value: %w", err)
+	}
+
+	if err := ps.Copy(args[0], args[1], force); err != nil {
+		return xerrors.Errorf("could not copy %q to %q: %w", args[0], args[1], err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bvk/past/store"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [flags] <password-file> [<old-revision> [<new-revision>]]",
+	Short: "Prints the field-level changes to a password-file between two revisions.",
+	Long: `Prints the field-level changes to a password-file between two revisions.
+
+When no revisions are given, changes from the most recent commit for the
+password-file are printed. When only the old revision is given, new revision
+is the HEAD. Revisions can also be dates, like "2020-05-01" or "last tuesday".`,
+	RunE: cmdDiff,
+}
+
+func init() {
+	flags := diffCmd.Flags()
+	flags.Bool("show-passwords", false, "When true, old and new passwords are printed.")
+}
+
+func cmdDiff(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+
+	if len(args) == 0 {
+		return xerrors.Errorf("password file argument is required: %w", os.ErrInvalid)
+	}
+	if len(args) > 3 {
+		return xerrors.Errorf("too many arguments: %w", os.ErrInvalid)
+	}
+	file := args[0]
+	showPasswords, err := flags.GetBool("show-passwords")
+	if err != nil {
+		return xerrors.Errorf("could not get --show-passwords value: %w", err)
+	}
+
+	var revs [2]string
+	switch len(args) {
+	case 1:
+		items, err := ps.Log(file)
+		if err != nil {
+			return xerrors.Errorf("could not get history for %q: %w", file, err)
+		}
+		if len(items) == 0 {
+			return xerrors.Errorf("password file %q has no history: %w", file, os.ErrNotExist)
+		}
+		revs = [2]string{items[0].Commit + "^", items[0].Commit}
+		if len(items) == 1 {
+			revs[0] = ""
+		}
+	case 2:
+		revs = [2]string{args[1], "HEAD"}
+	case 3:
+		revs = [2]string{args[1], args[2]}
+	}
+
+	// Password-file may not exist at one of the revisions, in which case all
+	// fields are reported as added or removed.
+	var versions [2][]byte
+	for i, rev := range revs {
+		if len(rev) == 0 {
+			continue
+		}
+		commit, err := ps.ResolveRevision(rev)
+		if err != nil {
+			return xerrors.Errorf("could not resolve revision %q: %w", rev, err)
+		}
+		data, err := ps.ReadFileAt(commit, file)
+		if err != nil && !xerrors.Is(err, os.ErrNotExist) {
+			return xerrors.Errorf("could not read %q at revision %q: %w", file, rev, err)
+		}
+		versions[i] = data
+	}
+
+	for _, c := range store.Diff(versions[0], versions[1]) {
+		name := changeName(c)
+		if len(c.Key) == 0 && !showPasswords {
+			fmt.Printf("~ %s%s\n", name, changeKind(c))
+			continue
+		}
+		if c.Old != nil {
+			fmt.Printf("- %s: %s\n", name, *c.Old)
+		}
+		if c.New != nil {
+			fmt.Printf("+ %s: %s\n", name, *c.New)
+		}
+	}
+	return nil
+}
@@ -85,6 +85,9 @@ func cmdEdit(cmd *cobra.Command, args []string) (status error) {
 		return nil
 	}
 
+	if err := checkPasswordRules(newDecrypted); err != nil {
+		return xerrors.Errorf("could not update file %q: %w", file, err)
+	}
 	if err := ps.UpdateFile(file, newDecrypted); err != nil {
 		return xerrors.Errorf("could not update file %q: %w", file, err)
 	}
@@ -0,0 +1,72 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+// envFlags maps the environment variables used by pass to the flags with the
+// same meaning. Flags are named as "subcmd/flag" for the subcommand flags.
+var envFlags = map[string]string{
+	"PASSWORD_STORE_DIR":              "data-dir",
+	"PASSWORD_STORE_GENERATED_LENGTH": "generate/length",
+	"PASSWORD_STORE_CHARACTER_SET":    "generate/character-set",
+	"PASSWORD_STORE_SIGNING_KEY":      "signing-key",
+}
+
+// setEnvDefaults replaces the default values for the flags with the values
+// from the pass environment variables. It must be called before the flags are
+// parsed, so that the flags given on the command-line take precedence.
+func setEnvDefaults(root *cobra.Command) error {
+	for env, name := range envFlags {
+		value, ok := os.LookupEnv(env)
+		if !ok || len(value) == 0 {
+			continue
+		}
+		flags := root.PersistentFlags()
+		if i := strings.IndexRune(name, '/'); i > 0 {
+			cmd, _, err := root.Find([]string{name[:i]})
+			if err != nil || cmd == root {
+				return xerrors.Errorf("could not find subcommand for flag %q: %w", name, os.ErrNotExist)
+			}
+			flags, name = cmd.Flags(), name[i+1:]
+		}
+		flag := flags.Lookup(name)
+		if flag == nil {
+			return xerrors.Errorf("could not find flag %q: %w", name, os.ErrNotExist)
+		}
+		// List values are separated by spaces in the environment variables.
+		if flag.Value.Type() == "stringSlice" {
+			value = strings.Join(strings.Fields(value), ",")
+		}
+		if err := flag.Value.Set(value); err != nil {
+			return xerrors.Errorf("could not use %s value %q for --%s flag: %w", env, value, name, err)
+		}
+		flag.DefValue = flag.Value.String()
+		presetFlags[flag] = true
+	}
+	return nil
+}
+
+// gpgOptions returns the additional options for the gpg commands from the
+// PASSWORD_STORE_GPG_OPTS environment variable.
+func gpgOptions() []string {
+	return strings.Fields(os.Getenv("PASSWORD_STORE_GPG_OPTS"))
+}
+
+// envSigningKeys returns the keys from the PASSWORD_STORE_SIGNING_KEY
+// environment variable, which must sign the .gpg-id files.
+func envSigningKeys() []string {
+	return strings.Fields(os.Getenv("PASSWORD_STORE_SIGNING_KEY"))
+}
+
+// envKeys returns the keys from the PASSWORD_STORE_KEY environment variable,
+// which are used in place of the keys from the .gpg-id files.
+func envKeys() []string {
+	return strings.Fields(os.Getenv("PASSWORD_STORE_KEY"))
+}
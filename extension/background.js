@@ -1,7 +1,63 @@
 'use strict';
 
+// Backend is kept running across the requests through a native messaging
+// port, so that keyring and password store are not reloaded for every
+// request. Backend responds to the requests in order, so callbacks are queued
+// in the same order.
+var backendPort = null;
+var backendCallbacks = [];
+
+// currentStore is the name of the password store used for the requests that
+// do not name a store. Empty name selects the default password store.
+var currentStore = "";
+
+// checkURLValues enables matching the `url:` values in the password-files with
+// the current tab, which decrypts all password-files once per session, so it
+// is off by default.
+var checkURLValues = false;
+
+getLocalStorage(["currentStore", "checkURLValues"], function(state) {
+  if (state && state.currentStore) {
+    currentStore = state.currentStore;
+  }
+  if (state && state.checkURLValues) {
+    checkURLValues = true;
+  }
+});
+
+function setCurrentStore(name) {
+  currentStore = name;
+  setLocalStorage({currentStore: name});
+}
+
+function setCheckURLValues(enabled) {
+  checkURLValues = enabled;
+  setLocalStorage({checkURLValues: enabled});
+}
+
 function callBackend(req, callback) {
-  chrome.runtime.sendNativeMessage('github.bvk.past', req, callback);
+  if (req.store === undefined) {
+    req.store = currentStore;
+  }
+  if (!backendPort) {
+    backendPort = chrome.runtime.connectNative('github.bvk.past');
+    backendPort.onMessage.addListener(function(resp) {
+      const cb = backendCallbacks.shift();
+      if (cb) {
+        cb(resp);
+      }
+    });
+    backendPort.onDisconnect.addListener(function() {
+      const callbacks = backendCallbacks;
+      backendPort = null;
+      backendCallbacks = [];
+      callbacks.forEach(function(cb) {
+        cb(undefined);
+      });
+    });
+  }
+  backendCallbacks.push(callback);
+  backendPort.postMessage(req);
 }
 
 function setLocalStorage(state, callback) {
@@ -253,7 +253,7 @@ function onEditPageCopyButton(page, copyButton) {
     setOperationStatus("Cleared.");
   };
 
-  if (backgroundPage.copyString(password, 10, whenCleared)) {
+  if (backgroundPage.copyString(password, clipboardTimeout, whenCleared)) {
     setOperationStatus("Copied.");
   } else {
     setOperationStatus("Cloud not copy.");
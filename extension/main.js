@@ -50,7 +50,15 @@ function onGetPersistentState(result) {
   });
 }
 
+// clipboardTimeout is the number of seconds to keep the copied passwords in
+// the clipboard, which can be configured in the backend config file.
+let clipboardTimeout = 10;
+
 function onStatusResponse(req, resp) {
+  if (resp && resp.check_status && resp.check_status.clipboard_timeout > 0) {
+    clipboardTimeout = resp.check_status.clipboard_timeout;
+  }
+
   let showSettings = true;
   if (resp &&
       resp.status == "" &&
@@ -2,6 +2,10 @@
 
 let passwordFiles;
 
+// urlMatchFiles holds the password files matching the active tab's url ordered
+// from the best match to the worst.
+let urlMatchFiles = [];
+
 function createSearchPage() {
   let searchPageTemplate = document.getElementById("search-page-template");
   let page = searchPageTemplate.cloneNode(true);
@@ -62,6 +66,30 @@ function onSearchPageListFilesResponse(page, req, resp) {
   if (searchBars) {
     searchBars[0].focus();
   }
+
+  if (activeTab && activeTab.url) {
+    let findReq = {find_for_url: {url: activeTab.url, check_values: backgroundPage.checkURLValues}};
+    backgroundPage.callBackend(findReq, function(resp) {
+      onSearchPageFindForURLResponse(page, findReq, resp);
+    });
+  }
+}
+
+function onSearchPageFindForURLResponse(page, req, resp) {
+  urlMatchFiles = [];
+  if (resp && resp.status == "" && resp.find_for_url && resp.find_for_url.matches) {
+    let matches = resp.find_for_url.matches;
+    for (let i = 0; i < matches.length; i++) {
+      urlMatchFiles.push(matches[i].filename);
+    }
+  }
+
+  let search = "";
+  var searchBars = page.getElementsByClassName("search-page-search-bar");
+  if (searchBars) {
+    search = searchBars[0].value;
+  }
+  setSearchPageRecentListItems(page, orderSearchPagePasswordFiles(search));
 }
 
 function onSearchPageSearchBar(page, searchInput) {
@@ -115,7 +143,7 @@ function onSearchPageViewFileResponseForCopy(page, req, resp) {
     setOperationStatus("Cleared.");
   };
 
-  if (backgroundPage.copyString(resp.view_file.password, 10, whenCleared)) {
+  if (backgroundPage.copyString(resp.view_file.password, clipboardTimeout, whenCleared)) {
     setOperationStatus("Copied.");
   } else {
     setOperationStatus("Cloud not copy.");
@@ -176,91 +204,35 @@ function sortSearchPagePasswordFiles() {
 }
 
 function orderSearchPagePasswordFiles(search) {
-  let hosts = getSearchPageHostnameSuffixes();
   let sortedFiles = sortSearchPagePasswordFiles();
 
   //
-  // If search string is empty, we want to bring hostname-matches first
-  // followed by all the rest in most-used order. Otherwise, i.e., if search
-  // string is non-empty, we only want to show search-maches and
-  // hostname-matches and nothing else.
+  // If search string is empty, we want to bring url-matches first followed by
+  // all the rest in most-used order. Otherwise, i.e., if search string is
+  // non-empty, we only want to show url-matches and search-matches and nothing
+  // else.
   //
 
-  let files = [];
+  let urlMatches = {};
+  for (let i = 0; i < urlMatchFiles.length; i++) {
+    urlMatches[urlMatchFiles[i]] = true;
+  }
+
+  let files = urlMatchFiles.slice();
   let skipped = [];
   for (let i = 0; i < sortedFiles.length; i++) {
-    if (search != "" && sortedFiles[i].includes(search)) {
-      files.push(sortedFiles[i]);
+    if (urlMatches[sortedFiles[i]]) {
       continue;
     }
-
-    let added = false;
-    for (let j = 0; j < hosts.length; j++) {
-      if (sortedFiles[i].includes(hosts[j])) {
-        files.push(sortedFiles[i]);
-        added = true;
-        break
-      }
-    }
-
-    if (!added) {
-      skipped.push(sortedFiles[i]);
-    }
-  }
-
-  let seen = {};
-  let dedup = [];
-  for (let i = 0; i < files.length; i++) {
-    if (seen[files[i]]) {
+    if (search != "" && sortedFiles[i].includes(search)) {
+      files.push(sortedFiles[i]);
       continue;
     }
-    seen[files[i]] = true;
-    dedup.push(files[i]);
+    skipped.push(sortedFiles[i]);
   }
 
   if (search == "") {
-    dedup = dedup.concat(skipped)
-  }
-  return dedup;
-}
-
-function getSearchPageHostnameSuffixes() {
-  if (!activeTab || !activeTab.url) {
-    return []
-  }
-
-  var a = document.createElement('a');
-  a.href = activeTab.url;
-
-  let hs = [a.hostname];
-  if (a.hostname != a.host) {
-    hs.push(a.host); // a.host could include the port number
-  }
-
-  if (isIP(a.hostname)) {
-    console.log(hs);
-    return hs;
-  }
-
-  // Split the hostnames into all suffixes with dot character.
-  var suffixes = [];
-  for (let i = 0; i < hs.length; i++) {
-    let words = hs[i].split(".");
-    for (let j = 0; j < words.length-1; j++) {
-      let ws = words.slice(j);
-      suffixes.push(ws.join("."));
-    }
-  }
-
-  console.log(suffixes);
-  return suffixes;
-}
-
-function isIP(hostname)
-{
- if (/^(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)$/.test(hostname))
-  {
-    return true
+    files = files.concat(skipped)
   }
-  return false
+  return files;
 }
@@ -42,9 +42,53 @@ function createSettingsPage(params) {
     onSettingsPageRemoteButton(page, remoteButton);
   });
 
+  let storeSelect = page.getElementsByClassName("settings-page-store-select")[0];
+  storeSelect.addEventListener("change", function() {
+    onSettingsPageStoreSelect(page, storeSelect);
+  });
+  loadSettingsPageStores(page, storeSelect);
+
+  let urlValuesCheckbox = page.getElementsByClassName("settings-page-urlvalues-checkbox")[0];
+  if (backgroundPage.checkURLValues) {
+    urlValuesCheckbox.textContent = "check_box";
+  }
+  urlValuesCheckbox.addEventListener("click", function() {
+    onSettingsPageURLValuesCheckbox(page, urlValuesCheckbox);
+  });
+
   return page;
 }
 
+// loadSettingsPageStores adds the named password stores to the store selector
+// and selects the current store.
+function loadSettingsPageStores(page, storeSelect) {
+  let req = {list_stores:{}};
+  backgroundPage.callBackend(req, function(resp) {
+    if (!resp || resp.status != "" || !resp.list_stores || !resp.list_stores.stores) {
+      return;
+    }
+    for (let i = 0; i < resp.list_stores.stores.length; i++) {
+      let option = document.createElement("option");
+      option.value = resp.list_stores.stores[i].name;
+      option.textContent = resp.list_stores.stores[i].name;
+      storeSelect.appendChild(option);
+    }
+    storeSelect.value = backgroundPage.currentStore;
+  });
+}
+
+function onSettingsPageURLValuesCheckbox(page, checkbox) {
+  let enabled = !backgroundPage.checkURLValues;
+  backgroundPage.setCheckURLValues(enabled);
+  checkbox.textContent = enabled ? "check_box" : "check_box_outline_blank";
+}
+
+function onSettingsPageStoreSelect(page, storeSelect) {
+  backgroundPage.setCurrentStore(storeSelect.value);
+  let checkButton = page.getElementsByClassName("settings-page-check-button")[0];
+  onSettingsPageCheckButton(page, checkButton);
+}
+
 function onSettingsPageDisplay(page) {
   let pageParams = page.getAttribute("page-params");
   let params = JSON.parse(pageParams);
@@ -87,7 +87,8 @@ function onSyncPageDisplay(page) {
   pullButton.disabled = false;
   pushButton.textContent = "publish";
   pullButton.textContent = "get_app";
-  setOperationStatus("Diverged. Syncing will overwrite.");
+  setOperationStatus("Diverged by " + params.sync_remote.ahead + " local and " +
+    params.sync_remote.behind + " remote commits. Syncing will merge the changes.");
 }
 
 function onSyncPageBackButton(page, backButton) {
@@ -99,7 +100,7 @@ function onSyncPageBackButton(page, backButton) {
 }
 
 function onSyncPageFetchButton(page, fetchButton) {
-  let req = {sync_remote:{fetch:true}};
+  let req = {sync_remote:syncPageTarget(page, {fetch:true})};
   callBackend(req, function(req, resp) {
     page.setAttribute("page-params", JSON.stringify(resp));
     onSyncPageDisplay(page);
@@ -107,7 +108,11 @@ function onSyncPageFetchButton(page, fetchButton) {
 }
 
 function onSyncPagePushButton(page, pushButton) {
-  let req = {sync_remote:{push:true}};
+  // Pushing a diverged history merges the remote changes first.
+  let req = {sync_remote:syncPageTarget(page, {push:true})};
+  if (isSyncPageDiverged(page)) {
+    req = {sync_remote:syncPageTarget(page, {sync:true})};
+  }
   callBackend(req, function(req, resp) {
     page.setAttribute("page-params", JSON.stringify(resp));
     onSyncPageDisplay(page);
@@ -115,9 +120,31 @@ function onSyncPagePushButton(page, pushButton) {
 }
 
 function onSyncPagePullButton(page, pullButton) {
-  let req = {sync_remote:{pull:true}};
+  let req = {sync_remote:syncPageTarget(page, {pull:true})};
   callBackend(req, function(req, resp) {
     page.setAttribute("page-params", JSON.stringify(resp));
     onSyncPageDisplay(page);
   });
 }
+
+function isSyncPageDiverged(page) {
+  let params = JSON.parse(page.getAttribute("page-params"));
+  if (!params || !params.sync_remote) {
+    return false;
+  }
+  let sync = params.sync_remote;
+  return sync.head.commit != sync.remote.commit &&
+    sync.newer_commit != sync.head.commit &&
+    sync.newer_commit != sync.remote.commit;
+}
+
+// syncPageTarget adds the remote and branch displayed in the page to the input
+// sync_remote request, so that all operations use the same remote branch.
+function syncPageTarget(page, req) {
+  let params = JSON.parse(page.getAttribute("page-params"));
+  if (params && params.sync_remote) {
+    req.remote = params.sync_remote.remote_name;
+    req.branch = params.sync_remote.branch;
+  }
+  return req;
+}
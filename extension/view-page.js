@@ -18,6 +18,11 @@ function createViewPage(req, resp) {
   let userdata = page.getElementsByClassName("view-page-userdata")[0];
   userdata.value = resp.view_file.data;
 
+  if (resp.view_file.has_otp) {
+    let otpRow = page.getElementsByClassName("view-page-otp-row")[0];
+    otpRow.style.display = "";
+  }
+
   let backButton = page.getElementsByClassName("view-page-back-button")[0];
   backButton.addEventListener("click", function() {
     onViewPageBackButton(page, backButton);
@@ -48,6 +53,11 @@ function createViewPage(req, resp) {
     onViewPagePassCopyButton(page, passCopyButton);
   });
 
+  let otpCopyButton = page.getElementsByClassName("view-page-otp-copy")[0];
+  otpCopyButton.addEventListener("click", function() {
+    onViewPageOTPCopyButton(page, otpCopyButton);
+  });
+
   let toggleButton = page.getElementsByClassName("view-page-password-toggle")[0];
   toggleButton.addEventListener("click", function() {
     onViewPageToggleButton(page, toggleButton);
@@ -98,13 +108,41 @@ function onViewPagePassCopyButton(page, copyButton) {
   };
 
   let password = page.getElementsByClassName("view-page-password")[0]
-  if (backgroundPage.copyString(password.value, 10, whenCleared)) {
+  if (backgroundPage.copyString(password.value, clipboardTimeout, whenCleared)) {
     setOperationStatus("Copied.");
   } else {
     setOperationStatus("Cloud not copy.");
   }
 }
 
+function onViewPageOTPCopyButton(page, copyButton) {
+  // One-time passwords are computed on demand, because HOTP counters are
+  // incremented for every code.
+  let title = page.getElementsByClassName("view-page-filename")[0]
+  let req = {otp:{filename:title.textContent}};
+  callBackend(req, function(req, resp) {
+    onViewPageOTPResponse(page, req, resp);
+  });
+}
+
+function onViewPageOTPResponse(page, req, resp) {
+  let whenCleared = function() {
+    setOperationStatus("Cleared.");
+  };
+
+  let code = page.getElementsByClassName("view-page-otp")[0];
+  code.value = resp.otp.code;
+  if (backgroundPage.copyString(code.value, clipboardTimeout, whenCleared)) {
+    if (resp.otp.type == "totp") {
+      setOperationStatus("Copied; expires in " + resp.otp.remaining + " seconds.");
+    } else {
+      setOperationStatus("Copied.");
+    }
+  } else {
+    setOperationStatus("Cloud not copy.");
+  }
+}
+
 function onViewPageUserCopyButton(page, copyButton) {
   let username = page.getElementsByClassName("view-page-username")[0]
   if (backgroundPage.copyString(username.value)) {
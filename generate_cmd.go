@@ -3,12 +3,15 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
 	"crypto/rand"
 	"math/big"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/bvk/past/store"
 
@@ -22,7 +25,12 @@ var NonSymbols = "ABCDEFGHIJKLMNOPQRSTUVWXYZ" + "abcdefghijklmnopqrstuvwxyz" + "
 var generateCmd = &cobra.Command{
 	Use:   "generate [flags] <password-file>",
 	Short: "Inserts a new password-file with an auto-generated password.",
-	RunE:  cmdGenerate,
+	Long: `Inserts a new password-file with an auto-generated password.
+
+With --in-place, password in an existing password-file is replaced and the rest
+of the password-file is kept. With --force, existing password-file is replaced
+completely. Both record the rotation time in the rotated_at key.`,
+	RunE: cmdGenerate,
 }
 
 func init() {
@@ -30,7 +38,23 @@ func init() {
 	flags.Bool("no-symbols", false, "When true, generated password contains only alphanumerics.")
 	flags.Uint("length", 10, "Number of characters in the generated password.")
 	flags.String("symbols", Symbols, "Acceptable set of symbols to use in the password.")
+	flags.String("character-set", "", "Set of characters to use in the password in place of alphanumerics and --symbols. Classes like [:alnum:] and ranges like a-z are accepted.")
 	flags.String("user", "", "Username to save along with the password.")
+	flags.Uint("words", 0, "When non-zero, generates a passphrase with the number of random words instead of random characters.")
+	flags.String("word-separator", "-", "Separator between the words in the generated passphrase.")
+	flags.String("wordlist-file", "", "Path to a wordlist file with one word per line to use in place of the EFF's large wordlist.")
+	flags.Bool("capitalize", false, "When true, first letter of every word in the passphrase is capitalized.")
+	flags.Bool("add-digit", false, "When true, a random digit is added to a random word in the passphrase.")
+	flags.Bool("add-symbol", false, "When true, a random symbol from --symbols is added to a random word in the passphrase.")
+	flags.Uint("min-upper", 0, "Minimum number of uppercase letters in the generated password.")
+	flags.Uint("min-lower", 0, "Minimum number of lowercase letters in the generated password.")
+	flags.Uint("min-digits", 0, "Minimum number of digits in the generated password.")
+	flags.Uint("min-symbols", 0, "Minimum number of symbols from --symbols in the generated password.")
+	flags.Bool("no-ambiguous", false, "When true, look-alike characters like 0, O, 1, l and I are not used in the generated password.")
+	flags.Uint("max-length", 0, "When non-zero, generated password is capped to the number of characters.")
+	flags.Bool("in-place", false, "When true, only the password in an existing password-file is replaced.")
+	flags.Bool("force", false, "When true, existing password-file with the same name is overwritten.")
+	flags.String("password-rules", "", "Password rules in the passwordrules syntax, like \"required: upper; allowed: [-_]; max-consecutive: 2\". Rules are saved with the password and are read from the passwordrules key of an existing password-file by default.")
 }
 
 func cmdGenerate(cmd *cobra.Command, args []string) (status error) {
@@ -59,46 +83,383 @@ func cmdGenerate(cmd *cobra.Command, args []string) (status error) {
 	if length == 0 {
 		return xerrors.Errorf("length value cannot be zero: %w", os.ErrInvalid)
 	}
+	symbols, err := flags.GetString("symbols")
+	if err != nil {
+		return xerrors.Errorf("could not get --symbols value: %w", err)
+	}
+	charset, err := flags.GetString("character-set")
+	if err != nil {
+		return xerrors.Errorf("could not get --character-set value: %w", err)
+	}
 	user, err := flags.GetString("user")
 	if err != nil {
 		return xerrors.Errorf("could not get --user value: %w", err)
 	}
+	words, err := flags.GetUint("words")
+	if err != nil {
+		return xerrors.Errorf("could not get --words value: %w", err)
+	}
+	separator, err := flags.GetString("word-separator")
+	if err != nil {
+		return xerrors.Errorf("could not get --word-separator value: %w", err)
+	}
+	wordlistFile, err := flags.GetString("wordlist-file")
+	if err != nil {
+		return xerrors.Errorf("could not get --wordlist-file value: %w", err)
+	}
+	capitalize, err := flags.GetBool("capitalize")
+	if err != nil {
+		return xerrors.Errorf("could not get --capitalize value: %w", err)
+	}
+	addDigit, err := flags.GetBool("add-digit")
+	if err != nil {
+		return xerrors.Errorf("could not get --add-digit value: %w", err)
+	}
+	addSymbol, err := flags.GetBool("add-symbol")
+	if err != nil {
+		return xerrors.Errorf("could not get --add-symbol value: %w", err)
+	}
+	minUpper, err := flags.GetUint("min-upper")
+	if err != nil {
+		return xerrors.Errorf("could not get --min-upper value: %w", err)
+	}
+	minLower, err := flags.GetUint("min-lower")
+	if err != nil {
+		return xerrors.Errorf("could not get --min-lower value: %w", err)
+	}
+	minDigits, err := flags.GetUint("min-digits")
+	if err != nil {
+		return xerrors.Errorf("could not get --min-digits value: %w", err)
+	}
+	minSymbols, err := flags.GetUint("min-symbols")
+	if err != nil {
+		return xerrors.Errorf("could not get --min-symbols value: %w", err)
+	}
+	noAmbiguous, err := flags.GetBool("no-ambiguous")
+	if err != nil {
+		return xerrors.Errorf("could not get --no-ambiguous value: %w", err)
+	}
+	maxLength, err := flags.GetUint("max-length")
+	if err != nil {
+		return xerrors.Errorf("could not get --max-length value: %w", err)
+	}
+	rules, err := flags.GetString("password-rules")
+	if err != nil {
+		return xerrors.Errorf("could not get --password-rules value: %w", err)
+	}
+	inPlace, err := flags.GetBool("in-place")
+	if err != nil {
+		return xerrors.Errorf("could not get --in-place value: %w", err)
+	}
+	force, err := flags.GetBool("force")
+	if err != nil {
+		return xerrors.Errorf("could not get --force value: %w", err)
+	}
+	if inPlace && force {
+		return xerrors.Errorf("--in-place and --force flags cannot be used together: %w", os.ErrInvalid)
+	}
+	exists, err := ps.FileExists(ps.EntryFile(file))
+	if err != nil {
+		return xerrors.Errorf("could not check if file %q exists: %w", file, err)
+	}
+	if inPlace && !exists {
+		return xerrors.Errorf("password file %q doesn't exist: %w", file, os.ErrNotExist)
+	}
+	// Password rules are saved in the password-file only when they are given
+	// with the flag, so that the rules saved earlier are not copied with
+	// the --force flag.
+	saveRules := len(rules) > 0
+	if len(rules) == 0 && exists {
+		if rules, err = readPasswordRules(ps, file); err != nil {
+			return xerrors.Errorf("could not read password rules from %q: %w", file, err)
+		}
+	}
+
+	dataDir, err := flags.GetString("data-dir")
+	if err != nil {
+		return xerrors.Errorf("could not get --data-dir value: %w", err)
+	}
+	config, err := loadConfig(dataDir)
+	if err != nil {
+		return xerrors.Errorf("could not load config: %w", err)
+	}
+	var password string
+	if words > 0 {
+		wordlist := effWordlist
+		if len(wordlistFile) > 0 {
+			if wordlist, err = readWordlist(wordlistFile); err != nil {
+				return xerrors.Errorf("could not read wordlist file %q: %w", wordlistFile, err)
+			}
+		}
+		var extras []string
+		if addDigit {
+			extras = append(extras, "0123456789")
+		}
+		if addSymbol {
+			extras = append(extras, symbols)
+		}
+		password, err = generatePassphrase(wordlist, words, separator, capitalize, extras)
+		if err != nil {
+			return xerrors.Errorf("could not generate passphrase: %w", err)
+		}
+		if min := config.Generate.MinLength; min != nil && uint(len(password)) < *min {
+			return xerrors.Errorf("passphrase cannot be shorter than the configured minimum %d: %w", *min, os.ErrInvalid)
+		}
+	}
 
 	// Generate the password.
 	var alnum = []byte(NonSymbols)
-	var graph = []byte(NonSymbols + Symbols)
+	var graph = []byte(NonSymbols + symbols)
+	if len(charset) > 0 {
+		chars, err := expandCharacterSet(charset)
+		if err != nil {
+			return xerrors.Errorf("could not parse --character-set value: %w", err)
+		}
+		graph = []byte(chars)
+	}
 	sort.Slice(alnum, func(i, j int) bool { return alnum[i] < alnum[j] })
 	sort.Slice(graph, func(i, j int) bool { return graph[i] < graph[j] })
 
-	var buf bytes.Buffer
-	if noSymbols {
-		max := big.NewInt(int64(len(alnum)))
-		for ii := uint(0); ii < length; ii++ {
-			v, err := rand.Int(rand.Reader, max)
+	if len(password) == 0 {
+		policy := &passwordPolicy{chars: string(graph)}
+		if noSymbols {
+			policy.chars = string(alnum)
+		}
+		if len(rules) > 0 {
+			rp, err := parsePasswordRules(rules)
 			if err != nil {
-				return xerrors.Errorf("could not generate secure random int: %w", err)
+				return xerrors.Errorf("could not parse password rules %q: %w", rules, err)
 			}
-			buf.WriteByte(alnum[int(v.Int64())])
+			if len(rp.chars) > 0 {
+				policy.chars = rp.chars
+			}
+			policy.required = rp.required
+			policy.minLength, policy.maxLength = rp.minLength, rp.maxLength
+			policy.maxConsecutive = rp.maxConsecutive
+		}
+		if maxLength > 0 && (policy.maxLength == 0 || int(maxLength) < policy.maxLength) {
+			policy.maxLength = int(maxLength)
+		}
+		if noAmbiguous {
+			policy.exclude = ambiguousChars
 		}
-	} else {
-		max := big.NewInt(int64(len(graph)))
-		for ii := uint(0); ii < length; ii++ {
-			v, err := rand.Int(rand.Reader, max)
+		policy.require(passwordClasses["upper"], int(minUpper))
+		policy.require(passwordClasses["lower"], int(minLower))
+		policy.require(passwordClasses["digit"], int(minDigits))
+		policy.require(symbols, int(minSymbols))
+
+		// Passwords must also satisfy the policy file for the password-file's
+		// directory, which is enforced when the password-file is written.
+		dirPolicy, err := ps.FilePolicy(ps.EntryFile(file))
+		if err != nil {
+			return xerrors.Errorf("could not read password policy for %q: %w", file, err)
+		}
+		if dirPolicy != nil {
+			if dirPolicy.MinLength > policy.minLength {
+				policy.minLength = dirPolicy.MinLength
+			}
+			for _, class := range dirPolicy.RequiredClasses {
+				if class == "symbol" {
+					policy.require(symbols, 1)
+				} else {
+					policy.require(passwordClasses[class], 1)
+				}
+			}
+		}
+
+		n := policy.length(int(length))
+		if min := config.Generate.MinLength; min != nil && uint(n) < *min {
+			return xerrors.Errorf("length value cannot be less than the configured minimum %d: %w", *min, os.ErrInvalid)
+		}
+		if password, err = policy.generate(n); err != nil {
+			return xerrors.Errorf("could not generate password: %w", err)
+		}
+		if err := policy.check(password); err != nil {
+			return xerrors.Errorf("could not generate password that satisfies the rules: %w", err)
+		}
+	}
+
+	if exists && (inPlace || force) {
+		data := store.Format(password, nil)
+		if inPlace {
+			decrypted, err := ps.ReadFile(file)
 			if err != nil {
-				return xerrors.Errorf("could not generate secure random int: %w", err)
+				return xerrors.Errorf("could not read file %q: %w", file, err)
 			}
-			buf.WriteByte(graph[int(v.Int64())])
+			_, rest := store.Parse(decrypted)
+			data = store.Format(password, rest)
+		}
+		if len(user) > 0 {
+			data = store.SetValue(data, "username", user)
 		}
+		if saveRules {
+			data = store.SetValue(data, "passwordrules", rules)
+		}
+		if err := ps.RotateFile(file, data); err != nil {
+			return xerrors.Errorf("could not rotate password in file %q: %w", file, err)
+		}
+		return nil
 	}
 
 	vs := store.NewValues(nil)
 	if len(user) > 0 {
 		vs.Set("username", user)
 	}
-
-	data := store.Format(buf.String(), vs.Bytes())
+	if len(rules) > 0 {
+		vs.Set("passwordrules", rules)
+	}
+	data := store.Format(password, vs.Bytes())
 	if err := ps.CreateFile(file, data, os.FileMode(0644)); err != nil {
 		return xerrors.Errorf("could not insert new file %q: %w", file, err)
 	}
 	return nil
 }
+
+// punctuation holds all ascii punctuation characters.
+var punctuation = `!"#$%&'()*+,-./:;<=>?@[\]^_{|}~` + "`"
+
+// characterClasses holds the characters for the character classes accepted in
+// the character sets, which are the same as the tr(1) command.
+var characterClasses = map[string]string{
+	"[:alnum:]":  NonSymbols,
+	"[:alpha:]":  "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+	"[:digit:]":  "0123456789",
+	"[:lower:]":  "abcdefghijklmnopqrstuvwxyz",
+	"[:upper:]":  "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"[:xdigit:]": "0123456789ABCDEFabcdef",
+	"[:punct:]":  punctuation,
+	"[:graph:]":  NonSymbols + punctuation,
+}
+
+// expandCharacterSet returns the unique characters in a character set like
+// the PASSWORD_STORE_CHARACTER_SET value in pass, which accepts character
+// classes like [:punct:] and character ranges like a-z.
+func expandCharacterSet(s string) (string, error) {
+	seen := make(map[byte]bool)
+	add := func(chars string) {
+		for i := 0; i < len(chars); i++ {
+			seen[chars[i]] = true
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] > '~' || s[i] < ' ' {
+			return "", xerrors.Errorf("only printable ascii characters are supported: %w", os.ErrInvalid)
+		}
+		if strings.HasPrefix(s[i:], "[:") {
+			if end := strings.Index(s[i:], ":]"); end >= 2 {
+				class := s[i : i+end+2]
+				chars, ok := characterClasses[class]
+				if !ok {
+					return "", xerrors.Errorf("invalid character class %q: %w", class, os.ErrInvalid)
+				}
+				add(chars)
+				i += len(class) - 1
+				continue
+			}
+		}
+		if i+2 < len(s) && s[i+1] == '-' {
+			if s[i] > s[i+2] {
+				return "", xerrors.Errorf("invalid character range %q: %w", s[i:i+3], os.ErrInvalid)
+			}
+			for c := s[i]; c <= s[i+2]; c++ {
+				seen[c] = true
+			}
+			i += 2
+			continue
+		}
+		seen[s[i]] = true
+	}
+	var chars []byte
+	for c := range seen {
+		chars = append(chars, c)
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+	return string(chars), nil
+}
+
+// randomInt returns a uniformly random number in [0, max) from the
+// crypto/rand source.
+func randomInt(max int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, xerrors.Errorf("could not generate secure random int: %w", err)
+	}
+	return int(v.Int64()), nil
+}
+
+// generatePassphrase returns a passphrase with random words from a wordlist.
+// A random character from each of the extra character sets is added at the
+// end of a random word.
+func generatePassphrase(wordlist []string, nwords uint, separator string, capitalize bool, extras []string) (string, error) {
+	if len(wordlist) < 2 {
+		return "", xerrors.Errorf("wordlist must have at least two words: %w", os.ErrInvalid)
+	}
+	words := make([]string, nwords)
+	for i := range words {
+		n, err := randomInt(len(wordlist))
+		if err != nil {
+			return "", err
+		}
+		words[i] = wordlist[n]
+		if capitalize {
+			r, size := utf8.DecodeRuneInString(words[i])
+			words[i] = string(unicode.ToUpper(r)) + words[i][size:]
+		}
+	}
+	for _, chars := range extras {
+		if len(chars) == 0 {
+			continue
+		}
+		c, err := randomInt(len(chars))
+		if err != nil {
+			return "", err
+		}
+		w, err := randomInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		words[w] += chars[c : c+1]
+	}
+	return strings.Join(words, separator), nil
+}
+
+// readWordlist returns the unique words from a wordlist file with one word per
+// line. Lines in the "<dice-rolls> <word>" form, like the EFF wordlists, are
+// also accepted.
+func readWordlist(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, xerrors.Errorf("could not open wordlist file: %w", err)
+	}
+	defer f.Close()
+
+	var words []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		word := fields[len(fields)-1]
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, xerrors.Errorf("could not read wordlist file: %w", err)
+	}
+	return words, nil
+}
+
+// readPasswordRules returns the passwordrules value from an existing
+// password-file.
+func readPasswordRules(ps *store.PasswordStore, file string) (string, error) {
+	decrypted, err := ps.ReadFile(file)
+	if err != nil {
+		return "", xerrors.Errorf("could not read file %q: %w", file, err)
+	}
+	_, data := store.Parse(decrypted)
+	return store.NewValues(data).Get("passwordrules"), nil
+}
@@ -2,6 +2,7 @@ package git
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -15,6 +16,15 @@ import (
 
 type Dir struct {
 	dir string
+
+	// signCommits when true signs all new commits with the signingKey or with
+	// the default key of the committer when signingKey is empty.
+	signCommits bool
+	signingKey  string
+
+	// verifier when not nil checks the commits before they are integrated into
+	// the current branch.
+	verifier func(from, to string) error
 }
 
 func NewDir(dir string) (*Dir, error) {
@@ -73,6 +83,132 @@ func (g *Dir) RemoveRemote(name string) error {
 	return nil
 }
 
+func (g *Dir) RenameRemote(oldName, newName string) error {
+	if err := g.run("remote", "rename", oldName, newName); err != nil {
+		return xerrors.Errorf("could not rename remote %q to %q: %w", oldName, newName, err)
+	}
+	return nil
+}
+
+// CurrentBranch returns the name of the checked out branch.
+func (g *Dir) CurrentBranch() (string, error) {
+	cmd := exec.Command("git", "-C", g.dir, "symbolic-ref", "--short", "HEAD")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", xerrors.Errorf("could not determine current branch (stderr: %s): %w", stderr.String(), err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Branches returns the local branch names.
+func (g *Dir) Branches() ([]string, error) {
+	return g.refNames("refs/heads")
+}
+
+// RemoteBranches returns the branch names in a remote as of the last fetch.
+func (g *Dir) RemoteBranches(remote string) ([]string, error) {
+	names, err := g.refNames("refs/remotes/" + remote)
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for _, name := range names {
+		branch := strings.TrimPrefix(name, remote+"/")
+		if branch != "HEAD" {
+			branches = append(branches, branch)
+		}
+	}
+	return branches, nil
+}
+
+func (g *Dir) refNames(prefix string) ([]string, error) {
+	cmd := exec.Command("git", "-C", g.dir, "for-each-ref", "--format=%(refname:short)", prefix)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, xerrors.Errorf("could not list refs under %q (stderr: %s): %w", prefix, stderr.String(), err)
+	}
+	return strings.Fields(stdout.String()), nil
+}
+
+// DefaultBranch returns the default branch of a remote as of the last fetch.
+// When remote's HEAD is not known, one of "main" or "master" branches is
+// returned if it exists in the remote.
+func (g *Dir) DefaultBranch(remote string) (string, error) {
+	cmd := exec.Command("git", "-C", g.dir, "symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(stdout.String()), remote+"/"), nil
+	}
+	for _, branch := range []string{"main", "master"} {
+		if g.HasRef("refs/remotes/" + remote + "/" + branch) {
+			return branch, nil
+		}
+	}
+	return "", xerrors.Errorf("could not determine default branch for remote %q: %w", remote, os.ErrNotExist)
+}
+
+// Upstream returns the remote and the remote branch configured as the
+// upstream for the current branch. It returns os.ErrNotExist if current
+// branch has no upstream.
+func (g *Dir) Upstream() (string, string, error) {
+	branch, err := g.CurrentBranch()
+	if err != nil {
+		return "", "", err
+	}
+	remote, err := g.GetConfig("branch." + branch + ".remote")
+	if err != nil {
+		return "", "", xerrors.Errorf("branch %q has no upstream: %w", branch, os.ErrNotExist)
+	}
+	merge, err := g.GetConfig("branch." + branch + ".merge")
+	if err != nil {
+		return "", "", xerrors.Errorf("branch %q has no upstream: %w", branch, os.ErrNotExist)
+	}
+	return remote, strings.TrimPrefix(merge, "refs/heads/"), nil
+}
+
+// SetUpstream configures a remote branch as the upstream for the current
+// branch.
+func (g *Dir) SetUpstream(remote, branch string) error {
+	current, err := g.CurrentBranch()
+	if err != nil {
+		return err
+	}
+	if err := g.SetConfg("branch."+current+".remote", remote); err != nil {
+		return xerrors.Errorf("could not set upstream remote: %w", err)
+	}
+	if err := g.SetConfg("branch."+current+".merge", "refs/heads/"+branch); err != nil {
+		return xerrors.Errorf("could not set upstream branch: %w", err)
+	}
+	return nil
+}
+
+// AheadBehind returns the number of commits in ref1 that are not in ref2 and
+// the number of commits in ref2 that are not in ref1.
+func (g *Dir) AheadBehind(ref1, ref2 string) (int, int, error) {
+	cmd := exec.Command("git", "-C", g.dir, "rev-list", "--left-right", "--count", ref1+"..."+ref2)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, 0, xerrors.Errorf("could not compare %q with %q (stderr: %s): %w", ref1, ref2, stderr.String(), err)
+	}
+	var ahead, behind int
+	if _, err := fmt.Sscanf(stdout.String(), "%d %d", &ahead, &behind); err != nil {
+		return 0, 0, xerrors.Errorf("could not parse commit counts %q: %w", stdout.String(), err)
+	}
+	return ahead, behind, nil
+}
+
+// Checkout creates or resets a branch to the start point and checks it out.
+func (g *Dir) Checkout(branch, startPoint string) error {
+	if err := g.run("checkout", "-q", "-B", branch, startPoint); err != nil {
+		return xerrors.Errorf("could not checkout branch %q at %q: %w", branch, startPoint, err)
+	}
+	return nil
+}
+
 func (g *Dir) GetRemoteURL(remote string) (string, error) {
 	cmd := exec.Command("git", "-C", g.dir, "remote", "get-url", remote)
 	var stdout, stderr bytes.Buffer
@@ -196,8 +332,42 @@ func (g *Dir) UpdateFile(path string, data []byte) (status error) {
 	return nil
 }
 
+// SetSignCommits enables or disables signing the new commits with a GPG key.
+// Default signing key of the committer is used when key is empty.
+func (g *Dir) SetSignCommits(sign bool, key string) {
+	g.signCommits, g.signingKey = sign, key
+}
+
+// signArgs returns the git options to sign the new commits, if enabled.
+func (g *Dir) signArgs() []string {
+	if !g.signCommits {
+		return nil
+	}
+	return []string{"--gpg-sign=" + g.signingKey}
+}
+
+// SetVerifier sets a function to check the commits from a remote branch that
+// are not in the current branch, before they are integrated into the current
+// branch. Verifier is called with the current branch and the remote branch.
+func (g *Dir) SetVerifier(verifier func(from, to string) error) {
+	g.verifier = verifier
+}
+
+// VerifyIncoming checks the commits that are reachable from the input ref, but
+// not from the current branch, with the verifier, if any.
+func (g *Dir) VerifyIncoming(ref string) error {
+	if g.verifier == nil {
+		return nil
+	}
+	if err := g.verifier("HEAD", ref); err != nil {
+		return xerrors.Errorf("could not verify commits from %q: %w", ref, err)
+	}
+	return nil
+}
+
 func (g *Dir) Commit(msg string) error {
-	commitCmd := exec.Command("git", "-C", g.dir, "commit", "-m", msg)
+	args := append([]string{"-C", g.dir, "commit"}, g.signArgs()...)
+	commitCmd := exec.Command("git", append(args, "-m", msg)...)
 	if err := commitCmd.Run(); err != nil {
 		return xerrors.Errorf("could not commit changes to the git repository: %w", err)
 	}
@@ -212,14 +382,115 @@ func (g *Dir) Reset(target string) error {
 	return nil
 }
 
+// PushOverwrite pushes the current branch to a remote branch, overwriting any
+// commits in the remote branch that are not in the current branch.
 func (g *Dir) PushOverwrite(remote, branch string) error {
-	cmd := exec.Command("git", "-C", g.dir, "push", "-u", "-f", remote, branch)
+	cmd := exec.Command("git", "-C", g.dir, "push", "-f", remote, "HEAD:refs/heads/"+branch)
 	if err := cmd.Run(); err != nil {
 		return xerrors.Errorf("could not push to remote %q, branch %q: %w", remote, branch, err)
 	}
 	return nil
 }
 
+// Push pushes the current branch to a remote branch. Push fails if the remote
+// branch has commits that are not in the current branch.
+func (g *Dir) Push(remote, branch string) error {
+	cmd := exec.Command("git", "-C", g.dir, "push", remote, "HEAD:refs/heads/"+branch)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return xerrors.Errorf("could not push to remote %q, branch %q (stderr: %s): %w", remote, branch, stderr.String(), err)
+	}
+	return nil
+}
+
+// Pull fetches the remote branch and integrates its commits into the current
+// branch. Current branch is fast-forwarded when possible. When the histories
+// have diverged, local commits are rebased on top of the remote branch and if
+// rebase fails, remote branch is merged instead. Local commits are never
+// discarded; when both rebase and merge fail, repository is left unchanged and
+// an error is returned.
+func (g *Dir) Pull(remote, branch string) error {
+	if err := g.Fetch(remote); err != nil {
+		return err
+	}
+	remoteRef := remote + "/" + branch
+	if !g.HasRef(remoteRef) {
+		return nil
+	}
+
+	if yes, err := g.IsAncestor(remoteRef, "HEAD"); err != nil {
+		return xerrors.Errorf("could not compare %q with HEAD: %w", remoteRef, err)
+	} else if yes {
+		return nil
+	}
+	if err := g.VerifyIncoming(remoteRef); err != nil {
+		return err
+	}
+	if yes, err := g.IsAncestor("HEAD", remoteRef); err != nil {
+		return xerrors.Errorf("could not compare HEAD with %q: %w", remoteRef, err)
+	} else if yes {
+		if err := g.run("merge", "--ff-only", remoteRef); err != nil {
+			return xerrors.Errorf("could not fast-forward to %q: %w", remoteRef, err)
+		}
+		return nil
+	}
+
+	rebaseErr := g.run(append(append([]string{"rebase"}, g.signArgs()...), remoteRef)...)
+	if rebaseErr == nil {
+		return nil
+	}
+	if err := g.run("rebase", "--abort"); err != nil {
+		return xerrors.Errorf("could not abort failed rebase on %q: %w", remoteRef, err)
+	}
+	log.Printf("could not rebase on %q (trying merge): %v", remoteRef, rebaseErr)
+
+	mergeMsg := fmt.Sprintf("Merged %s.", remoteRef)
+	mergeArgs := append([]string{"merge", "--no-edit"}, g.signArgs()...)
+	mergeErr := g.run(append(mergeArgs, "-m", mergeMsg, remoteRef)...)
+	if mergeErr == nil {
+		return nil
+	}
+	if err := g.run("merge", "--abort"); err != nil {
+		return xerrors.Errorf("could not abort failed merge with %q: %w", remoteRef, err)
+	}
+	return xerrors.Errorf("could not rebase or merge with %q: %w", remoteRef, mergeErr)
+}
+
+// Sync pulls the commits from the remote branch and pushes the local commits
+// to the remote branch.
+func (g *Dir) Sync(remote, branch string) error {
+	if err := g.Pull(remote, branch); err != nil {
+		return xerrors.Errorf("could not pull from remote %q: %w", remote, err)
+	}
+	remoteRef := remote + "/" + branch
+	if g.HasRef(remoteRef) {
+		if yes, err := g.IsAncestor("HEAD", remoteRef); err != nil {
+			return xerrors.Errorf("could not compare HEAD with %q: %w", remoteRef, err)
+		} else if yes {
+			return nil
+		}
+	}
+	if err := g.Push(remote, branch); err != nil {
+		return xerrors.Errorf("could not push to remote %q: %w", remote, err)
+	}
+	return nil
+}
+
+// HasRef returns true if input reference names a commit.
+func (g *Dir) HasRef(ref string) bool {
+	cmd := exec.Command("git", "-C", g.dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	return cmd.Run() == nil
+}
+
+func (g *Dir) run(args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", g.dir}, args...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return xerrors.Errorf("could not run git %s (output: %s): %w", args[0], strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
 func (g *Dir) Apply(msg string, cb func() error) (status error) {
 	defer func() {
 		if status != nil {
@@ -273,11 +544,11 @@ func (g *Dir) UnsetConfig(key string) error {
 }
 
 func (g *Dir) GetConfig(key string) (string, error) {
-	cmd := exec.Command("git", "-C", g.dir, "config", "--local", "--unset", key)
+	cmd := exec.Command("git", "-C", g.dir, "config", "--local", "--get", key)
 	var stdout bytes.Buffer
 	cmd.Stdout = &stdout
 	if err := cmd.Run(); err != nil {
-		return "", xerrors.Errorf("could not unset git config key %q: %w", key, err)
+		return "", xerrors.Errorf("could not get git config key %q: %w", key, err)
 	}
 	return strings.TrimSpace(stdout.String()), nil
 }
@@ -334,3 +605,118 @@ func (g *Dir) GetLogItem(ref string) (*LogItem, error) {
 	}
 	return item, nil
 }
+
+// Log returns the commits that modified any of the input paths, newest first.
+func (g *Dir) Log(paths ...string) ([]*LogItem, error) {
+	args := []string{"-C", g.dir, "log", "--format=%H%x1f%an <%ae>%x1f%aI%x1f%s", "--"}
+	cmd := exec.Command("git", append(args, paths...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, xerrors.Errorf("could not get git log for %q (stderr: %s): %w", paths, stderr.String(), err)
+	}
+	var items []*LogItem
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		fields := strings.SplitN(line, "\x1f", 4)
+		if len(fields) != 4 {
+			return nil, xerrors.Errorf("could not parse git log line %q: %w", line, os.ErrInvalid)
+		}
+		at, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return nil, xerrors.Errorf("could not parse author date %q: %w", fields[2], err)
+		}
+		items = append(items, &LogItem{
+			Commit:     fields[0],
+			Author:     fields[1],
+			AuthorDate: at,
+			Title:      fields[3],
+		})
+	}
+	return items, nil
+}
+
+// ReadFileAt returns the content of a file at a revision. It returns
+// os.ErrNotExist if the file doesn't exist at the revision.
+func (g *Dir) ReadFileAt(rev, path string) ([]byte, error) {
+	object := fmt.Sprintf("%s:%s", rev, filepath.ToSlash(filepath.Clean(path)))
+	if err := exec.Command("git", "-C", g.dir, "cat-file", "-e", object).Run(); err != nil {
+		return nil, xerrors.Errorf("could not find %q at revision %q: %w", path, rev, os.ErrNotExist)
+	}
+	cmd := exec.Command("git", "-C", g.dir, "cat-file", "blob", object)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, xerrors.Errorf("could not read %q at revision %q (stderr: %s): %w", path, rev, stderr.String(), err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// ResolveRevision returns the commit for a revision. Input can also be a date
+// in any format accepted by git, like "2020-05-01" or "last tuesday", in which
+// case the last commit before the date is returned.
+func (g *Dir) ResolveRevision(rev string) (string, error) {
+	cmd := exec.Command("git", "-C", g.dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err == nil {
+		return strings.TrimSpace(stdout.String()), nil
+	}
+
+	stdout.Reset()
+	cmd = exec.Command("git", "-C", g.dir, "rev-list", "-1", "--before="+rev, "HEAD")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", xerrors.Errorf("could not resolve revision %q: %w", rev, err)
+	}
+	commit := strings.TrimSpace(stdout.String())
+	if len(commit) == 0 {
+		return "", xerrors.Errorf("could not find any commit for revision %q: %w", rev, os.ErrNotExist)
+	}
+	return commit, nil
+}
+
+// CommitSignature holds the signature status of a commit.
+type CommitSignature struct {
+	Commit string `json:"commit"`
+
+	// Status is the signature status reported by git, which is "G" for a good
+	// signature, "U" for a good signature from a key with unknown validity and
+	// "N" when the commit is not signed.
+	Status string `json:"status"`
+
+	// Fingerprint is the fingerprint of the signing key, which may be a subkey
+	// of the PrimaryFingerprint key.
+	Fingerprint        string `json:"fingerprint"`
+	PrimaryFingerprint string `json:"primary_fingerprint"`
+}
+
+// CommitSignatures returns the signature status of the commits that are
+// reachable from the to ref, but not from the from ref.
+func (g *Dir) CommitSignatures(from, to string) ([]*CommitSignature, error) {
+	cmd := exec.Command("git", "-C", g.dir, "log", "--format=%H%x1f%G?%x1f%GF%x1f%GP", from+".."+to)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, xerrors.Errorf("could not get signatures for %s..%s (stderr: %s): %w", from, to, stderr.String(), err)
+	}
+	var sigs []*CommitSignature
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 4 {
+			return nil, xerrors.Errorf("could not parse git log line %q: %w", line, os.ErrInvalid)
+		}
+		sigs = append(sigs, &CommitSignature{
+			Commit:             fields[0],
+			Status:             fields[1],
+			Fingerprint:        fields[2],
+			PrimaryFingerprint: fields[3],
+		})
+	}
+	return sigs, nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bvk/past/git"
+	"github.com/bvk/past/store"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var gitTextconvCmd = &cobra.Command{
+	Use:   "git-textconv [flags] <file>",
+	Short: "Decrypts a password-file blob for git diffs.",
+	Long: `Decrypts a password-file blob for git diffs.
+
+Git invokes this command as the textconv filter for the password-files, so
+that git diff and git log -p commands show the decrypted changes. In redacted
+mode, password and the values for keys other than the well-known metadata keys
+are masked.`,
+	RunE: cmdGitTextconv,
+}
+
+// redactedValue replaces the secret values in the redacted mode.
+const redactedValue = "********"
+
+// metadataKeys holds the keys that are not redacted in the redacted mode.
+var metadataKeys = map[string]bool{
+	"sitename": true,
+	"username": true,
+	"user":     true,
+	"login":    true,
+	"email":    true,
+	"url":      true,
+	"website":  true,
+}
+
+func init() {
+	flags := gitTextconvCmd.Flags()
+	flags.Bool("redacted", false, "When true, password and non-metadata values are masked.")
+}
+
+func cmdGitTextconv(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	if len(args) != 1 {
+		return xerrors.Errorf("file argument is required: %w", os.ErrInvalid)
+	}
+	redacted, err := flags.GetBool("redacted")
+	if err != nil {
+		return xerrors.Errorf("could not get --redacted value: %w", err)
+	}
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+
+	file := args[0]
+	encrypted, err := ioutil.ReadFile(file)
+	if err != nil {
+		return xerrors.Errorf("could not read file %q: %w", file, err)
+	}
+	if len(encrypted) == 0 {
+		return nil
+	}
+
+	// Git preserves the file extension in the temporary file names, so the
+	// encryption format is chosen using the file name.
+	if !strings.HasSuffix(file, ".age") {
+		file = strings.TrimSuffix(file, ".gpg") + ".gpg"
+	}
+	decrypted, err := ps.Decrypt(file, encrypted)
+	if err != nil {
+		return xerrors.Errorf("could not decrypt file %q: %w", args[0], err)
+	}
+	if redacted {
+		decrypted = redact(decrypted)
+	}
+	fmt.Printf("%s", decrypted)
+	return nil
+}
+
+// redact masks the password and the values for keys other than the metadata
+// keys in a decrypted password-file.
+func redact(decrypted []byte) []byte {
+	password, data := store.Parse(decrypted)
+	if len(password) > 0 {
+		password = redactedValue
+	}
+	values := store.NewValues(data)
+	for _, key := range values.Keys() {
+		if !metadataKeys[strings.ToLower(key)] {
+			values.Set(key, redactedValue)
+		}
+	}
+	return store.Format(password, values.Bytes())
+}
+
+// registerTextconv configures the past git-textconv command for diffs of the
+// password-files in the repository configuration and the .gitattributes file.
+func registerTextconv(repo *git.Dir, redacted bool) error {
+	binaryPath, err := findBinaryPath(os.Args[0])
+	if err != nil {
+		return xerrors.Errorf("could not locate binary path: %w", err)
+	}
+	textconv := fmt.Sprintf("%s --data-dir %s git-textconv", shellQuote(binaryPath), shellQuote(repo.RootDir()))
+	if redacted {
+		textconv += " --redacted"
+	}
+	for _, driver := range []string{"gpg", "age"} {
+		if err := repo.SetConfg(fmt.Sprintf("diff.%s.textconv", driver), textconv); err != nil {
+			return xerrors.Errorf("could not configure textconv for %s files: %w", driver, err)
+		}
+	}
+	if err := addGitAttributes(repo, []string{"*.gpg diff=gpg", "*.age diff=age"}); err != nil {
+		return xerrors.Errorf("could not add diff attributes: %w", err)
+	}
+	return nil
+}
@@ -3,9 +3,12 @@ module github.com/bvk/past
 go 1.12
 
 require (
+	filippo.io/age v1.0.0
+	github.com/ProtonMail/go-crypto v0.0.0-20220113124808-70ae35bab23f
+	github.com/pelletier/go-toml v1.9.0
 	github.com/spf13/cobra v0.0.7
 	github.com/spf13/pflag v1.0.3
-	golang.org/x/crypto v0.0.0-20200403201458-baeed622b8d8
-	golang.org/x/sys v0.0.0-20200116001909-b77594299b42 // indirect
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
+	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2
 	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543
 )
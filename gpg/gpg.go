@@ -20,12 +20,23 @@ import (
 type Keyring struct {
 	keyring string
 
+	// extraOpts are the additional options for all gpg commands.
+	extraOpts []string
+
 	keyRecords  []*internal.Record
 	skeyRecords []*internal.Record
 }
 
 func NewKeyring(path string) (*Keyring, error) {
-	g := &Keyring{keyring: path}
+	return NewKeyringWithOptions(path, nil)
+}
+
+// NewKeyringWithOptions creates a keyring that passes the input options to all
+// gpg commands, like the PASSWORD_STORE_GPG_OPTS environment variable in pass.
+// GnuPG home directory is taken from the GNUPGHOME environment variable by the
+// gpg command itself.
+func NewKeyringWithOptions(path string, opts []string) (*Keyring, error) {
+	g := &Keyring{keyring: path, extraOpts: append([]string{}, opts...)}
 	if err := g.Refresh(); err != nil {
 		return nil, xerrors.Errorf("could not list gpg keys: %w", err)
 	}
@@ -93,7 +104,7 @@ func (g *Keyring) options() []string {
 	if len(g.keyring) > 0 {
 		opts = append(opts, "--no-keyring", "--keyring", g.keyring)
 	}
-	return opts
+	return append(opts, g.extraOpts...)
 }
 
 func (g *Keyring) Refresh() error {
@@ -163,6 +174,64 @@ func (g *Keyring) Encrypt(data []byte, fps []string) ([]byte, error) {
 	return stdout.Bytes(), nil
 }
 
+// Sign returns a detached signature for the input data with the secret key
+// identified by the input key id, fingerprint or email address.
+func (g *Keyring) Sign(data []byte, key string) ([]byte, error) {
+	cmd := exec.Command("gpg", "--detach-sign", "--local-user", key)
+	cmd.Args = append(cmd.Args, g.options()...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, xerrors.Errorf("could not sign with key %q (stderr: %q): %w", key, stderr.String(), err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Verify checks a detached signature for the input data and returns the
+// fingerprints of the signing key and its primary key.
+func (g *Keyring) Verify(data, signature []byte) ([]string, error) {
+	file, err := ioutil.TempFile("", "signature")
+	if err != nil {
+		return nil, xerrors.Errorf("could not create temporary file: %w", err)
+	}
+	defer func() {
+		os.Remove(file.Name())
+		file.Close()
+	}()
+	if _, err := file.Write(signature); err != nil {
+		return nil, xerrors.Errorf("could not write signature to temporary file: %w", err)
+	}
+
+	cmd := exec.Command("gpg", "--status-fd", "1", "--verify")
+	cmd.Args = append(cmd.Args, g.options()...)
+	cmd.Args = append(cmd.Args, file.Name(), "-")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, xerrors.Errorf("could not verify signature (stderr: %q): %w", stderr.String(), err)
+	}
+	return parseValidSignature(stdout.String())
+}
+
+// parseValidSignature returns the signing key and primary key fingerprints
+// from the VALIDSIG status line in gpg's --status-fd output.
+func parseValidSignature(status string) ([]string, error) {
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "[GNUPG:]" || fields[1] != "VALIDSIG" {
+			continue
+		}
+		fps := []string{fields[2]}
+		if len(fields) > 11 && fields[11] != fields[2] {
+			fps = append(fps, fields[11])
+		}
+		return fps, nil
+	}
+	return nil, xerrors.Errorf("could not find a valid signature: %w", os.ErrInvalid)
+}
+
 func (g *Keyring) Delete(fingerprint string) error {
 	cmd := exec.Command("gpg", "--delete-keys", "--yes")
 	cmd.Args = append(cmd.Args, g.options()...)
@@ -573,16 +642,34 @@ func GetRecipients(file string) ([]string, error) {
 		log.Printf("get recipients cmd %v failed with stderr %q", cmd.Args, stderr.String())
 		return nil, xerrors.Errorf("could not determine recipients: %w", err)
 	}
+	return parseRecipients(stderr.String()), nil
+}
+
+// Recipients returns the key ids that can decrypt the encrypted input data.
+func (g *Keyring) Recipients(data []byte) ([]string, error) {
+	cmd := exec.Command("gpg", "--list-only", "-d")
+	cmd.Args = append(cmd.Args, g.options()...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		log.Printf("get recipients cmd %v failed with stderr %q", cmd.Args, stderr.String())
+		return nil, xerrors.Errorf("could not determine recipients: %w", err)
+	}
+	return parseRecipients(stderr.String()), nil
+}
+
+func parseRecipients(stderr string) []string {
 	trim := func(r rune) bool {
 		return !strings.ContainsRune("0123456789ABCDEFabcdef", r)
 	}
 	// For some reason, GPG writes the ids to stderr.
 	var ids []string
-	fields := strings.Fields(stderr.String())
+	fields := strings.Fields(stderr)
 	for ii := 1; ii < len(fields); ii++ {
 		if fields[ii-1] == "ID" {
 			ids = append(ids, strings.TrimFunc(fields[ii], trim))
 		}
 	}
-	return ids, nil
+	return ids
 }
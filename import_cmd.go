@@ -4,11 +4,16 @@ package main
 
 import (
 	"encoding/csv"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/bvk/past/otp"
+	"github.com/bvk/past/qr"
 	"github.com/bvk/past/store"
 
 	"github.com/spf13/cobra"
@@ -27,6 +32,7 @@ func init() {
 	flags.Bool("overwrite", false, "When true, existing files with matching name will be overwritten.")
 	flags.Bool("ignore-failures", false, "When true, failures are ignored till all entries are processed.")
 	flags.String("chrome-passwords-file", "", "Path to Chrome passwords data file.")
+	flags.StringSlice("otp-qr-image", nil, "Path to PNG or JPEG images with otpauth:// or otpauth-migration:// QR codes.")
 }
 
 func cmdImport(cmd *cobra.Command, args []string) error {
@@ -42,6 +48,16 @@ func cmdImport(cmd *cobra.Command, args []string) error {
 		}
 		imported = true
 	}
+	otpImages, err := flags.GetStringSlice("otp-qr-image")
+	if err != nil {
+		return xerrors.Errorf("could not get --otp-qr-image value: %w", err)
+	}
+	for _, file := range otpImages {
+		if err := importOTPImage(flags, file); err != nil {
+			return xerrors.Errorf("could not import otp secrets from %q: %w", file, err)
+		}
+		imported = true
+	}
 	if !imported {
 		return xerrors.Errorf("use one of the flags to specify password data file: %w", os.ErrInvalid)
 	}
@@ -138,3 +154,121 @@ func importChromePasswords(flags *pflag.FlagSet, file string) error {
 	}
 	return nil
 }
+
+func importOTPImage(flags *pflag.FlagSet, file string) error {
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+	overwrite, err := flags.GetBool("overwrite")
+	if err != nil {
+		return xerrors.Errorf("could not get --overwrite value: %w", err)
+	}
+	ignoreFailures, err := flags.GetBool("ignore-failures")
+	if err != nil {
+		return xerrors.Errorf("could not get --ignore-failures value: %w", err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return xerrors.Errorf("could not open image file %q: %w", file, err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return xerrors.Errorf("could not decode image file %q: %w", file, err)
+	}
+	data, err := qr.Decode(img)
+	if err != nil {
+		return xerrors.Errorf("could not decode qr code: %w", err)
+	}
+
+	var keys []*otp.Key
+	uri := strings.TrimSpace(string(data))
+	if strings.HasPrefix(uri, "otpauth-migration:") {
+		if keys, err = otp.ParseMigration(uri); err != nil {
+			return xerrors.Errorf("could not parse otp migration data: %w", err)
+		}
+	} else {
+		key, err := otp.Parse(uri)
+		if err != nil {
+			return xerrors.Errorf("could not parse otp uri: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		filename, err := otpFilename(key)
+		if err != nil {
+			if !ignoreFailures {
+				return xerrors.Errorf("could not determine password-file for otp secret %q: %w", key.Label, err)
+			}
+			log.Printf("could not determine password-file for otp secret %q: %v", key.Label, err)
+			continue
+		}
+		if err := addOTPKey(ps, filename, key, overwrite); err != nil {
+			if !ignoreFailures {
+				return xerrors.Errorf("could not add otp secret to %s: %w", filename, err)
+			}
+			log.Printf("could not add otp secret to password-file %q: %v", filename, err)
+			continue
+		}
+		log.Printf("added otp secret to password-file %q", filename)
+	}
+	return nil
+}
+
+// otpFilename returns the password-file name for an OTP key as issuer and
+// account name pair, like the chrome passwords. Since the names come from the
+// QR codes, path separators in the names are replaced and the names that are
+// empty or start with a dot are rejected.
+func otpFilename(key *otp.Key) (string, error) {
+	account := key.Label
+	issuer := key.Issuer
+	if i := strings.Index(account, ":"); i >= 0 {
+		if len(issuer) == 0 {
+			issuer = account[:i]
+		}
+		account = account[i+1:]
+	}
+	replacer := strings.NewReplacer("/", "_", "\\", "_")
+	issuer = strings.TrimSpace(replacer.Replace(issuer))
+	account = strings.TrimSpace(replacer.Replace(account))
+	if len(account) == 0 {
+		return "", xerrors.Errorf("otp account name cannot be empty: %w", os.ErrInvalid)
+	}
+	for _, name := range []string{issuer, account} {
+		if strings.HasPrefix(name, ".") {
+			return "", xerrors.Errorf("otp issuer or account name %q cannot start with a dot: %w", name, os.ErrInvalid)
+		}
+	}
+	return filepath.Join(issuer, account), nil
+}
+
+// addOTPKey adds an OTP key to an existing password-file or creates a new
+// password-file with an empty password. Existing OTP secret is replaced only
+// when overwrite is true.
+func addOTPKey(ps *store.PasswordStore, filename string, key *otp.Key, overwrite bool) error {
+	exists, err := ps.FileExists(ps.EntryFile(filename))
+	if err != nil {
+		return xerrors.Errorf("could not check for password-file %q: %w", filename, err)
+	}
+	if !exists {
+		values := store.NewValues(nil)
+		setOTPKey(values, key)
+		return ps.CreateFile(filename, store.Format("", values.Bytes()), os.FileMode(0644))
+	}
+
+	decrypted, err := ps.ReadFile(filename)
+	if err != nil {
+		return xerrors.Errorf("could not read file %q: %w", filename, err)
+	}
+	password, data := store.Parse(decrypted)
+	values := store.NewValues(data)
+	if old, _ := getOTPKey(values, filename); old != nil && !overwrite {
+		return xerrors.Errorf("password file %q already has an otp secret: %w", filename, os.ErrExist)
+	}
+	setOTPKey(values, key)
+	return ps.UpdateFile(filename, store.Format(password, values.Bytes()))
+}
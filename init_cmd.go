@@ -5,16 +5,20 @@ package main
 import (
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/bvk/past/agecrypt"
 	"github.com/bvk/past/git"
 	"github.com/bvk/past/gpg"
+	"github.com/bvk/past/store"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"golang.org/x/xerrors"
 )
 
@@ -27,24 +31,52 @@ var initCmd = &cobra.Command{
 func init() {
 	flags := initCmd.Flags()
 	flags.Bool("skip-decrypt-failures", false, "When true, files that could not be decrypted will be skipped.")
+	flags.StringP("path", "p", ".", "Sub-directory of the password-store to re-initialize with the keys.")
+	flags.Bool("age", false, "When true, arguments are age recipients and password-files are encrypted with age.")
+	flags.Bool("merge-driver", false, "When true, past merge-driver is registered to merge the password-files in git.")
+	flags.String("textconv", "", "When \"full\" or \"redacted\", past git-textconv is registered to show decrypted git diffs.")
 }
 
 func cmdInit(cmd *cobra.Command, args []string) error {
 	flags := cmd.Flags()
-	if len(args) == 0 {
-		return xerrors.Errorf("at least one GPG id argument is required: %w", os.ErrInvalid)
-	}
 	dataDir, err := flags.GetString("data-dir")
 	if err != nil {
 		return xerrors.Errorf("could not get --data-dir value: %w", err)
 	}
+	mergeDriver, err := flags.GetBool("merge-driver")
+	if err != nil {
+		return xerrors.Errorf("could not get --merge-driver value: %w", err)
+	}
+	textconv, err := flags.GetString("textconv")
+	if err != nil {
+		return xerrors.Errorf("could not get --textconv value: %w", err)
+	}
+	if textconv != "" && textconv != "full" && textconv != "redacted" {
+		return xerrors.Errorf("--textconv value must be \"full\" or \"redacted\": %w", os.ErrInvalid)
+	}
 
 	create := false
 	if _, err := os.Stat(dataDir); err != nil {
 		create = true
 	}
 
+	// Git configuration can be updated for an existing password store without
+	// re-initializing it.
+	if len(args) == 0 && !create && (mergeDriver || len(textconv) > 0) {
+		return setupGit(dataDir, mergeDriver, textconv)
+	}
+	if len(args) == 0 {
+		return xerrors.Errorf("at least one GPG id or age recipient argument is required: %w", os.ErrInvalid)
+	}
+
 	if create {
+		path, err := flags.GetString("path")
+		if err != nil {
+			return xerrors.Errorf("could not get --path value: %w", err)
+		}
+		if p := filepath.Clean(path); p != "." {
+			return xerrors.Errorf("password store must be created before initializing %q: %w", p, os.ErrInvalid)
+		}
 		if err := doCreate(cmd, args); err != nil {
 			return xerrors.Errorf("could not create password store: %w", err)
 		}
@@ -53,6 +85,28 @@ func cmdInit(cmd *cobra.Command, args []string) error {
 			return xerrors.Errorf("could not re-initialize password store: %w", err)
 		}
 	}
+	return setupGit(dataDir, mergeDriver, textconv)
+}
+
+// setupGit configures the optional git integrations for the password store.
+func setupGit(dataDir string, mergeDriver bool, textconv string) error {
+	if !mergeDriver && len(textconv) == 0 {
+		return nil
+	}
+	repo, err := git.NewDir(dataDir)
+	if err != nil {
+		return xerrors.Errorf("could not create git directory instance: %w", err)
+	}
+	if mergeDriver {
+		if err := registerMergeDriver(repo); err != nil {
+			return xerrors.Errorf("could not register merge driver: %w", err)
+		}
+	}
+	if len(textconv) > 0 {
+		if err := registerTextconv(repo, textconv == "redacted"); err != nil {
+			return xerrors.Errorf("could not register textconv: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -62,31 +116,9 @@ func doCreate(cmd *cobra.Command, args []string) (status error) {
 	if err != nil {
 		return xerrors.Errorf("could not get --data-dir value: %w", err)
 	}
-	keyring, err := gpg.NewKeyring("")
+	idFile, fps, err := getInitKeys(flags, args)
 	if err != nil {
-		return xerrors.Errorf("could not create gpg key ring instance: %w", err)
-	}
-
-	// Args should be encryptable key-ids or key-fingerprints or email ids
-	// associated with encryptable key-ids that are not expired yet.
-	now := time.Now()
-	pks := keyring.PublicKeys()
-	var keys []*gpg.PublicKey
-	for _, arg := range args {
-		var key *gpg.PublicKey
-		for _, pk := range pks {
-			if pk.CanEncrypt && (pk.ExpiresAt.IsZero() || pk.ExpiresAt.After(now)) {
-				if strings.EqualFold(pk.KeyID, arg) || strings.EqualFold(pk.Fingerprint, arg) ||
-					strings.EqualFold(pk.UserEmail, arg) {
-					key = pk
-					break
-				}
-			}
-		}
-		if key == nil {
-			return xerrors.Errorf("could not find a valid gpg key for %q: %w", arg, os.ErrInvalid)
-		}
-		keys = append(keys, key)
+		return xerrors.Errorf("could not determine encryption keys: %w", err)
 	}
 
 	initCmd := exec.Command("git", "init", dataDir)
@@ -107,13 +139,31 @@ func doCreate(cmd *cobra.Command, args []string) (status error) {
 		return xerrors.Errorf("could not create git directory instance: %w", err)
 	}
 
-	fps := []string{}
-	for _, key := range keys {
-		fps = append(fps, key.Fingerprint)
-	}
 	data := []byte(strings.Join(fps, "\n") + "\n")
-	if err := repo.CreateFile(".gpg-id", data, os.FileMode(0644)); err != nil {
-		return xerrors.Errorf("could not create .gpg-id file with the key ids: %w", err)
+	if err := repo.CreateFile(idFile, data, os.FileMode(0644)); err != nil {
+		return xerrors.Errorf("could not create %s file with the key ids: %w", idFile, err)
+	}
+
+	signingKeys, err := flags.GetStringSlice("signing-key")
+	if err != nil {
+		return xerrors.Errorf("could not get --signing-key value: %w", err)
+	}
+	if len(signingKeys) > 0 && idFile == ".gpg-id" {
+		crypter, err := newCrypter(flags)
+		if err != nil {
+			return xerrors.Errorf("could not create encryption backend: %w", err)
+		}
+		signer, ok := crypter.(store.Signer)
+		if !ok {
+			return xerrors.Errorf("encryption backend cannot create signatures: %w", os.ErrInvalid)
+		}
+		signature, err := store.SignFile(signer, data, signingKeys)
+		if err != nil {
+			return xerrors.Errorf("could not sign %s file: %w", idFile, err)
+		}
+		if err := repo.CreateFile(idFile+".sig", signature, os.FileMode(0644)); err != nil {
+			return xerrors.Errorf("could not create %s.sig file: %w", idFile, err)
+		}
 	}
 	msg := fmt.Sprintf("Created password store with keys %q", fps)
 	if err := repo.Commit(msg); err != nil {
@@ -124,25 +174,76 @@ func doCreate(cmd *cobra.Command, args []string) (status error) {
 
 func doReinit(cmd *cobra.Command, args []string) (status error) {
 	flags := cmd.Flags()
-	dataDir, err := flags.GetString("data-dir")
+	path, err := flags.GetString("path")
 	if err != nil {
-		return xerrors.Errorf("could not get --data-dir value: %w", err)
+		return xerrors.Errorf("could not get --path value: %w", err)
 	}
 	skipDecryptFailures, err := flags.GetBool("skip-decrypt-failures")
 	if err != nil {
 		return xerrors.Errorf("could not get --skip-decrypt-failures value: %w", err)
 	}
+	nskip := 0
+	if skipDecryptFailures {
+		nskip = math.MaxInt32
+	}
 
-	repo, err := git.NewDir(dataDir)
+	ps, err := newPasswordStore(flags)
 	if err != nil {
-		return xerrors.Errorf("could not create git directory instance: %w", err)
+		return xerrors.Errorf("could not create password store instance: %w", err)
 	}
-	keyring, err := gpg.NewKeyring("")
+	idFile, fps, err := getInitKeys(flags, args)
 	if err != nil {
-		return xerrors.Errorf("could not create gpg key ring instance: %w", err)
+		return xerrors.Errorf("could not determine encryption keys: %w", err)
+	}
+
+	if idFile == ".age-recipients" {
+		if err := ps.ReinitAge(path, fps, nskip); err != nil {
+			return xerrors.Errorf("could not reinitialize %q with new age recipients: %w", path, err)
+		}
+		return nil
 	}
-	// Args should be encryptable key-ids or key-fingerprints or email ids
-	// associated with encryptable key-ids that are not expired yet.
+	if err := ps.Reinit(path, fps, nskip); err != nil {
+		return xerrors.Errorf("could not reinitialize %q with new keys: %w", path, err)
+	}
+	return nil
+}
+
+// getInitKeys returns the keys file name and the keys for the init command
+// arguments. Arguments are age recipients with the --age flag; otherwise,
+// they identify GPG keys.
+func getInitKeys(flags *pflag.FlagSet, args []string) (string, []string, error) {
+	useAge, err := flags.GetBool("age")
+	if err != nil {
+		return "", nil, xerrors.Errorf("could not get --age value: %w", err)
+	}
+	if useAge {
+		for _, arg := range args {
+			if !agecrypt.IsRecipient(arg) {
+				return "", nil, xerrors.Errorf("%q is not a valid age recipient: %w", arg, os.ErrInvalid)
+			}
+		}
+		return ".age-recipients", args, nil
+	}
+
+	keyring, err := newCrypter(flags)
+	if err != nil {
+		return "", nil, xerrors.Errorf("could not create encryption backend: %w", err)
+	}
+	keys, err := findEncryptionKeys(keyring, args)
+	if err != nil {
+		return "", nil, xerrors.Errorf("could not find encryption keys: %w", err)
+	}
+	fps := []string{}
+	for _, key := range keys {
+		fps = append(fps, key.Fingerprint)
+	}
+	return ".gpg-id", fps, nil
+}
+
+// findEncryptionKeys returns the public keys for the input arguments. Args
+// should be encryptable key-ids or key-fingerprints or email ids associated
+// with encryptable key-ids that are not expired yet.
+func findEncryptionKeys(keyring store.Crypter, args []string) ([]*gpg.PublicKey, error) {
 	now := time.Now()
 	pks := keyring.PublicKeys()
 	var keys []*gpg.PublicKey
@@ -158,108 +259,9 @@ func doReinit(cmd *cobra.Command, args []string) (status error) {
 			}
 		}
 		if key == nil {
-			return xerrors.Errorf("could not find a valid gpg key for %q: %w", arg, os.ErrInvalid)
+			return nil, xerrors.Errorf("could not find a valid gpg key for %q: %w", arg, os.ErrInvalid)
 		}
 		keys = append(keys, key)
 	}
-
-	gitFiles, err := repo.ListFiles()
-	if err != nil {
-		return xerrors.Errorf("could not list files in the git directory: %w", err)
-	}
-
-	// Scan all git files to identify .gpg-id files for each directory.
-	dirKeysMap := make(map[string][]string)
-	for _, path := range gitFiles {
-		base := filepath.Base(path)
-		if base == ".gpg-id" {
-			data, err := repo.ReadFile(path)
-			if err != nil {
-				return xerrors.Errorf("could not read file %q: %w", path, err)
-			}
-			dirKeysMap[filepath.Dir(path)] = strings.Fields(string(data))
-		}
-	}
-	if _, ok := dirKeysMap["."]; !ok {
-		return xerrors.Errorf("could not find .gpg-id file in the git root directory: %w", os.ErrInvalid)
-	}
-
-	// Scan all git files to identify the encrypted .gpg files and their encryption key ids.
-	fileKeyDirMap := make(map[string]string)
-	for _, path := range gitFiles {
-		if strings.HasSuffix(path, ".gpg") {
-			keyDir := "."
-			for d := filepath.Dir(path); d != "."; d = filepath.Dir(d) {
-				if _, ok := dirKeysMap[d]; ok {
-					keyDir = d
-					break
-				}
-			}
-			fileKeyDirMap[path] = keyDir
-		}
-	}
-
-	// Decrypt and re-encrypt all files using the default keys.
-	nupdated := 0
-	defer func() {
-		if nupdated > 0 && status != nil {
-			if err := repo.Reset("HEAD"); err != nil {
-				log.Printf("could not reset repo changes on a failure (needs manual cleanup): %v", err)
-			}
-		}
-	}()
-
-	skipped := []string{}
-	for path, dir := range fileKeyDirMap {
-		if dir != "." {
-			continue
-		}
-		data, err := repo.ReadFile(path)
-		if err != nil {
-			return xerrors.Errorf("could not read file %q: %w", path, err)
-		}
-		decrypted, err := keyring.Decrypt(data)
-		if err != nil {
-			if !skipDecryptFailures {
-				return xerrors.Errorf("could not decrypt file %q: %w", path, err)
-			}
-			skipped = append(skipped, path)
-		}
-		var fps []string
-		for _, key := range keys {
-			fps = append(fps, key.Fingerprint)
-		}
-		encrypted, err := keyring.Encrypt(decrypted, fps)
-		if err != nil {
-			return xerrors.Errorf("could not rencrypt %q with new keys: %w", path, err)
-		}
-		if err := repo.UpdateFile(path, encrypted); err != nil {
-			return xerrors.Errorf("could not update file %q in git repo: %w", path, err)
-		}
-		log.Printf("re-encrypted %q with new keys", path)
-		nupdated++
-	}
-
-	if nupdated == 0 && len(fileKeyDirMap) > 0 {
-		return xerrors.Errorf("could not decrypt any file: %w", err)
-	}
-
-	// Update the .gpg-id file in root dir with th key fingerprints.
-	fps := []string{}
-	for _, key := range keys {
-		fps = append(fps, key.Fingerprint)
-	}
-	if err := repo.UpdateFile(".gpg-id", []byte(strings.Join(fps, "\n")+"\n")); err != nil {
-		return xerrors.Errorf("could not update .gpg-id file with new keys: %w", err)
-	}
-
-	msg := fmt.Sprintf("Reinitialized the repo with new keys %q", args)
-	if err := repo.Commit(msg); err != nil {
-		return xerrors.Errorf("could not commit reinitialize change: %w", err)
-	}
-
-	if len(skipped) > 0 {
-		log.Printf("warning: could not decrypt files %q, so they are skipped", skipped)
-	}
-	return nil
+	return keys, nil
 }
@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/xerrors"
@@ -21,6 +22,12 @@ var ExtensionIDs = []string{
 	"ogoijmgpcpnebocggiecngklnlekohmo", // Local developement version.
 }
 
+// FirefoxExtensionIDs holds the extension ids used by Firefox, which are
+// configured with the browser_specific_settings in the extension manifest.
+var FirefoxExtensionIDs = []string{
+	"past@github.bvk", // Firefox version.
+}
+
 // Mappings for native-messaging-hosts config file directory location (for
 // current-user and system-wide) on each os for each browser.
 var nativeMsgHostsDirMap = map[string]map[string][2]string{
@@ -34,6 +41,26 @@ var nativeMsgHostsDirMap = map[string]map[string][2]string{
 			filepath.Join(os.Getenv("HOME"), ".config/chromium/NativeMessagingHosts"),
 			"/etc/chromium/native-messaging-hosts/",
 		},
+
+		"brave-browser": [2]string{
+			filepath.Join(os.Getenv("HOME"), ".config/BraveSoftware/Brave-Browser/NativeMessagingHosts"),
+			"/etc/opt/chrome/native-messaging-hosts",
+		},
+
+		"vivaldi": [2]string{
+			filepath.Join(os.Getenv("HOME"), ".config/vivaldi/NativeMessagingHosts"),
+			"/etc/opt/vivaldi/native-messaging-hosts",
+		},
+
+		"microsoft-edge": [2]string{
+			filepath.Join(os.Getenv("HOME"), ".config/microsoft-edge/NativeMessagingHosts"),
+			"/etc/opt/edge/native-messaging-hosts",
+		},
+
+		"firefox": [2]string{
+			filepath.Join(os.Getenv("HOME"), ".mozilla/native-messaging-hosts"),
+			"/usr/lib/mozilla/native-messaging-hosts",
+		},
 	},
 	"darwin": map[string][2]string{
 		"google-chrome": [2]string{
@@ -44,6 +71,10 @@ var nativeMsgHostsDirMap = map[string]map[string][2]string{
 			filepath.Join(os.Getenv("HOME"), "/Library/Application Support/Chromium/NativeMessagingHosts/"),
 			"/Library/Application Support/Chromium/NativeMessagingHosts/",
 		},
+		"firefox": [2]string{
+			filepath.Join(os.Getenv("HOME"), "/Library/Application Support/Mozilla/NativeMessagingHosts/"),
+			"/Library/Application Support/Mozilla/NativeMessagingHosts/",
+		},
 	},
 }
 
@@ -78,6 +109,9 @@ func cmdInstall(cmd *cobra.Command, args []string) error {
 	if dev {
 		extID = ExtensionIDs[1]
 	}
+	if isFirefox(browser) {
+		extID = FirefoxExtensionIDs[0]
+	}
 	extDir, err := flags.GetString("extension-dir")
 	if err != nil {
 		return xerrors.Errorf("could not get --extension-dir value: %w", err)
@@ -149,21 +183,28 @@ func cmdInstall(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Firefox identifies the extensions with allowed_extensions and the
+	// chromium based browsers with allowed_origins.
 	type NativeMsgHostConfig struct {
-		Name           string   `json:"name"`
-		Description    string   `json:"description"`
-		Path           string   `json:"path"`
-		Type           string   `json:"type"`
-		AllowedOrigins []string `json:"allowed_origins"`
+		Name              string   `json:"name"`
+		Description       string   `json:"description"`
+		Path              string   `json:"path"`
+		Type              string   `json:"type"`
+		AllowedOrigins    []string `json:"allowed_origins,omitempty"`
+		AllowedExtensions []string `json:"allowed_extensions,omitempty"`
 	}
 	var nativeCfg = &NativeMsgHostConfig{
 		Name:        nativeMsgHostsName,
 		Description: "Native messaging host config for past.",
 		Path:        copyPath,
 		Type:        "stdio",
-		AllowedOrigins: []string{
+	}
+	if isFirefox(browser) {
+		nativeCfg.AllowedExtensions = []string{extID}
+	} else {
+		nativeCfg.AllowedOrigins = []string{
 			fmt.Sprintf("chrome-extension://%s/", extID),
-		},
+		}
 	}
 
 	cfgData, err := json.MarshalIndent(nativeCfg, "", "    ")
@@ -177,7 +218,11 @@ func cmdInstall(cmd *cobra.Command, args []string) error {
 	}
 	log.Printf("native messaging host config file is created at %q", file)
 
-	// Open chrome to prompt the user for installing the extension.
+	// Open the browser to prompt the user for installing the extension.
+	if isFirefox(browser) {
+		log.Printf("load the extension from about:debugging in firefox to install the extension manually")
+		return nil
+	}
 	if extID == ExtensionIDs[0] {
 		address := "https://chrome.google.com/webstore/detail/password-store-extension/lpjgobmcekjengejhfbambleokkelpjb"
 		if err := openBrowser(browser, address); err != nil {
@@ -187,6 +232,33 @@ func cmdInstall(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func isFirefox(browser string) bool {
+	return browser == "firefox"
+}
+
+// isBrowserInvocation returns true if the command-line arguments indicate
+// that this program is started by a browser extension. Chromium based browsers
+// pass the extension origin as the only argument where as Firefox passes the
+// native messaging host config file path and the extension id.
+func isBrowserInvocation(args []string) bool {
+	if len(args) == 1 {
+		for _, extID := range ExtensionIDs {
+			extensionArg := fmt.Sprintf("chrome-extension://%s", extID)
+			if strings.HasPrefix(args[0], extensionArg) {
+				return true
+			}
+		}
+	}
+	if len(args) == 2 {
+		for _, extID := range FirefoxExtensionIDs {
+			if args[1] == extID && strings.HasSuffix(args[0], ".json") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func findBinaryPath(name string) (string, error) {
 	bp, err := exec.LookPath(name)
 	if err != nil {
@@ -214,6 +286,9 @@ func openBrowser(browser, address string) error {
 		if browser == "chromium" {
 			return exec.Command("open", "-a", "Chromium", address).Run()
 		}
+		if browser == "firefox" {
+			return exec.Command("open", "-a", "Firefox", address).Run()
+		}
 	}
 	return os.ErrInvalid
 }
@@ -40,9 +40,9 @@ func cmdKeys(cmd *cobra.Command, args []string) error {
 		return xerrors.Errorf("could not get --unexpired value: %w", err)
 	}
 
-	keyring, err := gpg.NewKeyring("")
+	keyring, err := newCrypter(flags)
 	if err != nil {
-		return xerrors.Errorf("could not create keys key ring instance: %w", err)
+		return xerrors.Errorf("could not create encryption backend: %w", err)
 	}
 
 	now := time.Now()
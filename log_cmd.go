@@ -0,0 +1,86 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bvk/past/store"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var logCmd = &cobra.Command{
+	Use:   "log [flags] <password-file>",
+	Short: "Prints the history of a password-file with the changed fields.",
+	RunE:  cmdLog,
+}
+
+func cmdLog(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+
+	if len(args) != 1 {
+		return xerrors.Errorf("password file argument is required: %w", os.ErrInvalid)
+	}
+	file := args[0]
+
+	items, err := ps.Log(file)
+	if err != nil {
+		return xerrors.Errorf("could not get history for %q: %w", file, err)
+	}
+	if len(items) == 0 {
+		return xerrors.Errorf("password file %q has no history: %w", file, os.ErrNotExist)
+	}
+
+	// Versions are decrypted once each by comparing every version with the
+	// version from the next older commit.
+	versions := make([][]byte, len(items)+1)
+	errs := make([]error, len(items)+1)
+	for i, item := range items {
+		versions[i], errs[i] = ps.ReadFileAt(item.Commit, file)
+		if errs[i] != nil && xerrors.Is(errs[i], os.ErrNotExist) {
+			versions[i], errs[i] = nil, nil
+		}
+	}
+
+	for i, item := range items {
+		fmt.Printf("%s %s %s\n", item.Commit[:12], item.AuthorDate.Format("2006-01-02 15:04"), item.Author)
+		fmt.Printf("    %s\n", item.Title)
+		if errs[i] != nil || errs[i+1] != nil {
+			fmt.Printf("    changes: unknown (could not decrypt)\n")
+			continue
+		}
+		var fields []string
+		for _, c := range store.Diff(versions[i+1], versions[i]) {
+			fields = append(fields, changeName(c)+changeKind(c))
+		}
+		if len(fields) > 0 {
+			fmt.Printf("    changes: %s\n", strings.Join(fields, ", "))
+		}
+	}
+	return nil
+}
+
+func changeName(c *store.Change) string {
+	if len(c.Key) == 0 {
+		return "password"
+	}
+	return c.Key
+}
+
+func changeKind(c *store.Change) string {
+	switch {
+	case c.Old == nil:
+		return " (added)"
+	case c.New == nil:
+		return " (removed)"
+	}
+	return ""
+}
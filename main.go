@@ -4,13 +4,14 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
+
+	"github.com/bvk/past/agecrypt"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
 )
 
 func main() {
@@ -23,16 +24,11 @@ func doMain(ctx context.Context) error {
 	flags := mainCmd.PersistentFlags()
 	flags.String("data-dir", filepath.Join(os.Getenv("HOME"), ".password-store"),
 		"Data directory for the password store.")
-
-	// If this program is invoked by chrome extension, just execute the chrome handler.
-	if len(os.Args) == 2 {
-		for _, extID := range ExtensionIDs {
-			extensionArg := fmt.Sprintf("chrome-extension://%s", extID)
-			if strings.HasPrefix(os.Args[1], extensionArg) {
-				return cmdChrome(flags, os.Args[1:])
-			}
-		}
-	}
+	flags.String("backend", "gpg", "Encryption backend to use; one of \"gpg\" or \"native\".")
+	flags.StringSlice("keyring-file", nil, "OpenPGP keyring files for the native backend.")
+	flags.String("age-identity-file", agecrypt.DefaultIdentitiesFile(), "Identities file for the age encrypted password-files.")
+	flags.String("store", "", "Name of the password store to use from the stores list.")
+	flags.StringSlice("signing-key", nil, "Keys that must sign the .gpg-id files in the password store.")
 
 	mainCmd.AddCommand(initCmd)
 	mainCmd.AddCommand(listCmd)
@@ -45,6 +41,36 @@ func doMain(ctx context.Context) error {
 	mainCmd.AddCommand(showCmd)
 	mainCmd.AddCommand(installCmd)
 	mainCmd.AddCommand(importCmd)
+	mainCmd.AddCommand(rmCmd)
+	mainCmd.AddCommand(mvCmd)
+	mainCmd.AddCommand(cpCmd)
+	mainCmd.AddCommand(mergeDriverCmd)
+	mainCmd.AddCommand(gitTextconvCmd)
+	mainCmd.AddCommand(logCmd)
+	mainCmd.AddCommand(diffCmd)
+	mainCmd.AddCommand(restoreCmd)
+	mainCmd.AddCommand(storesCmd)
+	mainCmd.AddCommand(otpCmd)
+
+	// Defaults from the user's config file are loaded before the flags are
+	// parsed, so that flags take precedence over the config values.
+	config, err := loadConfig("")
+	if err != nil {
+		return xerrors.Errorf("could not load config: %w", err)
+	}
+	if err := setFlagDefaults(mainCmd, config); err != nil {
+		return xerrors.Errorf("could not apply config: %w", err)
+	}
+	// Environment variables from pass take precedence over the user's config.
+	if err := setEnvDefaults(mainCmd); err != nil {
+		return xerrors.Errorf("could not apply environment variables: %w", err)
+	}
+
+	// If this program is invoked by a browser extension, just execute the chrome
+	// handler.
+	if isBrowserInvocation(os.Args[1:]) {
+		return cmdChrome(flags, os.Args[1:])
+	}
 
 	mainCmd.SilenceUsage = true
 	mainCmd.SilenceErrors = true
@@ -57,4 +83,24 @@ func doMain(ctx context.Context) error {
 var mainCmd = &cobra.Command{
 	Use:   "past subcmd [flags]",
 	Short: "Manages GPG encrypted password-files in a Git repository.",
+
+	PersistentPreRunE: cmdSetup,
+}
+
+// cmdSetup updates the flags that are not given on the command-line with the
+// values from the selected store and from the password store's config file.
+func cmdSetup(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	if err := applyStore(flags); err != nil {
+		return err
+	}
+	dataDir, err := flags.GetString("data-dir")
+	if err != nil {
+		return xerrors.Errorf("could not get --data-dir value: %w", err)
+	}
+	config, err := loadConfig(dataDir)
+	if err != nil {
+		return xerrors.Errorf("could not load config: %w", err)
+	}
+	return applyConfig(cmd, config)
 }
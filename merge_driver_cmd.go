@@ -0,0 +1,97 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bvk/past/git"
+	"github.com/bvk/past/store"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var mergeDriverCmd = &cobra.Command{
+	Use:   "merge-driver [flags] <base> <current> <other> <path>",
+	Short: "Merges password-files field-by-field as a git merge driver.",
+	Long: `Merges password-files field-by-field as a git merge driver.
+
+Git invokes this command with the temporary files for the common ancestor,
+current and other versions of a password-file and the password-file path
+(%O %A %B %P). Merged content is re-encrypted into the current version file.
+Command fails when the same field is changed differently on both sides.`,
+	RunE: cmdMergeDriver,
+}
+
+func cmdMergeDriver(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	if len(args) != 4 {
+		return xerrors.Errorf("base, current, other and path arguments are required: %w", os.ErrInvalid)
+	}
+	baseFile, currentFile, otherFile, path := args[0], args[1], args[2], args[3]
+
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+
+	// Base version file is empty when there is no common ancestor.
+	var versions [3][]byte
+	for i, file := range []string{baseFile, currentFile, otherFile} {
+		encrypted, err := ioutil.ReadFile(file)
+		if err != nil {
+			return xerrors.Errorf("could not read file %q: %w", file, err)
+		}
+		if len(encrypted) == 0 {
+			continue
+		}
+		decrypted, err := ps.Decrypt(path, encrypted)
+		if err != nil {
+			return xerrors.Errorf("could not decrypt %q version of %q: %w", file, path, err)
+		}
+		versions[i] = decrypted
+	}
+
+	merged, conflicts := store.Merge(versions[0], versions[1], versions[2])
+	if len(conflicts) > 0 {
+		return xerrors.Errorf("could not merge %q due to conflicting changes in fields %q: %w", path, conflicts, os.ErrExist)
+	}
+
+	encrypted, err := ps.Encrypt(path, merged)
+	if err != nil {
+		return xerrors.Errorf("could not encrypt merged content for %q: %w", path, err)
+	}
+	if err := ioutil.WriteFile(currentFile, encrypted, os.FileMode(0644)); err != nil {
+		return xerrors.Errorf("could not write merged content for %q: %w", path, err)
+	}
+	return nil
+}
+
+// registerMergeDriver configures the past merge driver for the password-files
+// in the repository configuration and the .gitattributes file.
+func registerMergeDriver(repo *git.Dir) error {
+	binaryPath, err := findBinaryPath(os.Args[0])
+	if err != nil {
+		return xerrors.Errorf("could not locate binary path: %w", err)
+	}
+	driver := fmt.Sprintf("%s --data-dir %s merge-driver %%O %%A %%B %%P", shellQuote(binaryPath), shellQuote(repo.RootDir()))
+	if err := repo.SetConfg("merge.past.name", "past password-file merge driver"); err != nil {
+		return xerrors.Errorf("could not configure merge driver name: %w", err)
+	}
+	if err := repo.SetConfg("merge.past.driver", driver); err != nil {
+		return xerrors.Errorf("could not configure merge driver: %w", err)
+	}
+	if err := addGitAttributes(repo, []string{"*.gpg merge=past", "*.age merge=past"}); err != nil {
+		return xerrors.Errorf("could not add merge attributes: %w", err)
+	}
+	return nil
+}
+
+// shellQuote quotes a string for use in the git configured commands.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var mvCmd = &cobra.Command{
+	Use:   "mv [flags] <old-path> <new-path>",
+	Short: "Renames a password-file or a directory, re-encrypting as necessary.",
+	RunE:  cmdMv,
+}
+
+func init() {
+	flags := mvCmd.Flags()
+	flags.BoolP("force", "f", false, "When true, existing files at the destination are overwritten.")
+}
+
+func cmdMv(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+
+	if len(args) != 2 {
+		return xerrors.Errorf("old and new path arguments are required: %w", os.ErrInvalid)
+	}
+	force, err := flags.GetBool("force")
+	if err != nil {
+		return xerrors.Errorf("could not get --force value: %w", err)
+	}
+
+	if err := ps.Move(args[0], args[1], force); err != nil {
+		return xerrors.Errorf("could not move %q to %q: %w", args[0], args[1], err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package otp
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// ParseMigration returns the keys from an otpauth-migration://offline?data=...
+// URI, which is used by the Google Authenticator app to export the accounts.
+// Data is a base64 encoded protocol buffer message with the account secrets.
+func ParseMigration(uri string) ([]*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, xerrors.Errorf("could not parse migration uri: %w", err)
+	}
+	if u.Scheme != "otpauth-migration" || u.Host != "offline" {
+		return nil, xerrors.Errorf("migration uri must be in otpauth-migration://offline form: %w", os.ErrInvalid)
+	}
+	data := u.Query().Get("data")
+	payload, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		if payload, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "=")); err != nil {
+			return nil, xerrors.Errorf("could not decode migration data: %w", err)
+		}
+	}
+
+	var keys []*Key
+	err = parseMessage(payload, func(field int, value []byte, number uint64) error {
+		// Field 1 is the repeated OtpParameters message.
+		if field != 1 || value == nil {
+			return nil
+		}
+		key, err := parseParameters(value)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("could not parse migration data: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, xerrors.Errorf("migration data has no otp secrets: %w", os.ErrInvalid)
+	}
+	return keys, nil
+}
+
+// parseParameters returns the key from an OtpParameters message.
+func parseParameters(msg []byte) (*Key, error) {
+	key := &Key{Type: TOTP, Algorithm: "SHA1", Digits: 6, Period: 30}
+	var name string
+	err := parseMessage(msg, func(field int, value []byte, number uint64) error {
+		switch field {
+		case 1:
+			key.Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(value)
+		case 2:
+			name = string(value)
+		case 3:
+			key.Issuer = string(value)
+		case 4:
+			switch number {
+			case 2:
+				key.Algorithm = "SHA256"
+			case 3:
+				key.Algorithm = "SHA512"
+			case 0, 1:
+			default:
+				return xerrors.Errorf("unsupported otp algorithm %d: %w", number, os.ErrInvalid)
+			}
+		case 5:
+			if number == 2 {
+				key.Digits = 8
+			}
+		case 6:
+			if number == 1 {
+				key.Type = HOTP
+			}
+		case 7:
+			key.Counter = number
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(key.Secret) == 0 {
+		return nil, xerrors.Errorf("otp secret cannot be empty: %w", os.ErrInvalid)
+	}
+
+	// Names are usually in "issuer:account" form, but the issuer is also
+	// saved separately.
+	key.Label = name
+	if len(key.Issuer) > 0 && !strings.HasPrefix(name, key.Issuer+":") {
+		key.Label = key.Issuer + ":" + name
+	}
+	return key, nil
+}
+
+// parseMessage calls the callback for each field of a protocol buffer message
+// with the bytes for the length-delimited fields or with the number for the
+// varint fields.
+func parseMessage(msg []byte, cb func(field int, value []byte, number uint64) error) error {
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return xerrors.Errorf("invalid field tag: %w", os.ErrInvalid)
+		}
+		msg = msg[n:]
+		field := int(tag >> 3)
+
+		switch tag & 7 {
+		case 0: // Varint.
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return xerrors.Errorf("invalid varint in field %d: %w", field, os.ErrInvalid)
+			}
+			msg = msg[n:]
+			if err := cb(field, nil, v); err != nil {
+				return err
+			}
+		case 2: // Length-delimited.
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || size > uint64(len(msg)-n) {
+				return xerrors.Errorf("invalid length in field %d: %w", field, os.ErrInvalid)
+			}
+			value := msg[n : n+int(size)]
+			msg = msg[n+int(size):]
+			if err := cb(field, value, 0); err != nil {
+				return err
+			}
+		case 1: // Fixed 64 bits.
+			if len(msg) < 8 {
+				return xerrors.Errorf("invalid fixed64 in field %d: %w", field, os.ErrInvalid)
+			}
+			msg = msg[8:]
+		case 5: // Fixed 32 bits.
+			if len(msg) < 4 {
+				return xerrors.Errorf("invalid fixed32 in field %d: %w", field, os.ErrInvalid)
+			}
+			msg = msg[4:]
+		default:
+			return xerrors.Errorf("unsupported wire type %d in field %d: %w", tag&7, field, os.ErrInvalid)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package otp
+
+import (
+	"testing"
+)
+
+func TestParseMigration(t *testing.T) {
+	testCases := []struct {
+		uri  string
+		want []Key
+	}{
+		{
+			// Export of a single account from the Google Authenticator app.
+			uri: "otpauth-migration://offline?data=CjEKCkhlbGxvId6tvu8SGEV4YW1wbGU6YWxpY2VAZ29vZ2xlLmNvbRoHRXhhbXBsZSABKAEwAhABGAEgACjr4JP9Bg%3D%3D",
+			want: []Key{
+				{
+					Type:      TOTP,
+					Label:     "Example:alice@google.com",
+					Issuer:    "Example",
+					Secret:    "JBSWY3DPEHPK3PXP",
+					Algorithm: "SHA1",
+					Digits:    6,
+					Period:    30,
+				},
+			},
+		},
+		{
+			// Export with a TOTP account and an HOTP account.
+			uri: "otpauth-migration://offline?data=CjUKFDEyMzQ1Njc4OTAxMjM0NTY3ODkwEg9ib2JAZXhhbXBsZS5jb20aBkdpdEh1YiABKAEwAgoiCgphYmNkZWZnaGlqEgpDb3JwOmNhcm9sGgRDb3JwMAE4BRAB",
+			want: []Key{
+				{
+					Type:      TOTP,
+					Label:     "GitHub:bob@example.com",
+					Issuer:    "GitHub",
+					Secret:    "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+					Algorithm: "SHA1",
+					Digits:    6,
+					Period:    30,
+				},
+				{
+					Type:      HOTP,
+					Label:     "Corp:carol",
+					Issuer:    "Corp",
+					Secret:    "MFRGGZDFMZTWQ2LK",
+					Algorithm: "SHA1",
+					Digits:    6,
+					Period:    30,
+					Counter:   5,
+				},
+			},
+		},
+		{
+			// Export with an 8 digit SHA256 TOTP account and a SHA512 HOTP account.
+			uri: "otpauth-migration://offline?data=CjEKEXNoYTI1Ni1zZWNyZXQta2V5EhBkYXZlQGV4YW1wbGUub3JnGgRCYW5rIAIoAjACCiwKEXNoYTUxMi1zZWNyZXQta2V5EglTaG9wOmVyaW4aBFNob3AgAygBMAE4AhAB",
+			want: []Key{
+				{
+					Type:      TOTP,
+					Label:     "Bank:dave@example.org",
+					Issuer:    "Bank",
+					Secret:    "ONUGCMRVGYWXGZLDOJSXILLLMV4Q",
+					Algorithm: "SHA256",
+					Digits:    8,
+					Period:    30,
+				},
+				{
+					Type:      HOTP,
+					Label:     "Shop:erin",
+					Issuer:    "Shop",
+					Secret:    "ONUGCNJRGIWXGZLDOJSXILLLMV4Q",
+					Algorithm: "SHA512",
+					Digits:    6,
+					Period:    30,
+					Counter:   2,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		keys, err := ParseMigration(tc.uri)
+		if err != nil {
+			t.Errorf("%s: could not parse: %v", tc.uri, err)
+			continue
+		}
+		if len(keys) != len(tc.want) {
+			t.Errorf("%s: got %d keys, want %d", tc.uri, len(keys), len(tc.want))
+			continue
+		}
+		for i, key := range keys {
+			if *key != tc.want[i] {
+				t.Errorf("%s: key %d: got %+v, want %+v", tc.uri, i, *key, tc.want[i])
+			}
+		}
+	}
+}
+
+func TestParseMigrationErrors(t *testing.T) {
+	for _, uri := range []string{
+		"otpauth://totp/x?secret=JBSWY3DPEHPK3PXP",
+		"otpauth-migration://online?data=CgA%3D",
+		"otpauth-migration://offline?data=%%%",
+		"otpauth-migration://offline?data=CgMSAWE%3D",
+		"otpauth-migration://offline?data=EAE%3D",
+	} {
+		if _, err := ParseMigration(uri); err == nil {
+			t.Errorf("%s: want an error", uri)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+// Package otp implements the HOTP (RFC 4226) and TOTP (RFC 6238) one-time
+// passwords with the keys in the otpauth:// URI format used by the
+// authenticator apps.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	TOTP = "totp"
+	HOTP = "hotp"
+)
+
+// Key holds the parameters for generating one-time passwords.
+type Key struct {
+	// Type is one of TOTP or HOTP.
+	Type string
+
+	// Label is the name of the account, which is usually in "issuer:account"
+	// format.
+	Label  string
+	Issuer string
+
+	// Secret is the base32 encoded shared secret.
+	Secret string
+
+	// Algorithm is one of SHA1, SHA256 or SHA512.
+	Algorithm string
+
+	// Digits is the number of digits in the one-time passwords.
+	Digits int
+
+	// Period is the number of seconds each TOTP password is valid for.
+	Period int
+
+	// Counter is the next HOTP counter value.
+	Counter uint64
+}
+
+// NewTOTP returns a TOTP key with the default parameters for a base32 encoded
+// secret.
+func NewTOTP(label, secret string) (*Key, error) {
+	key := &Key{
+		Type:      TOTP,
+		Label:     label,
+		Secret:    normalizeSecret(secret),
+		Algorithm: "SHA1",
+		Digits:    6,
+		Period:    30,
+	}
+	if _, err := key.secretBytes(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Parse parses a key in the otpauth://TYPE/LABEL?PARAMETERS format.
+func Parse(uri string) (*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, xerrors.Errorf("could not parse otp uri: %w", err)
+	}
+	if u.Scheme != "otpauth" {
+		return nil, xerrors.Errorf("otp uri must have the otpauth scheme: %w", os.ErrInvalid)
+	}
+	key := &Key{
+		Type:      strings.ToLower(u.Host),
+		Label:     strings.TrimPrefix(u.Path, "/"),
+		Algorithm: "SHA1",
+		Digits:    6,
+		Period:    30,
+	}
+	if key.Type != TOTP && key.Type != HOTP {
+		return nil, xerrors.Errorf("unsupported otp type %q: %w", u.Host, os.ErrInvalid)
+	}
+
+	params := u.Query()
+	key.Secret = normalizeSecret(params.Get("secret"))
+	if _, err := key.secretBytes(); err != nil {
+		return nil, err
+	}
+	key.Issuer = params.Get("issuer")
+	if v := params.Get("algorithm"); len(v) > 0 {
+		key.Algorithm = strings.ToUpper(v)
+		if newHash(key.Algorithm) == nil {
+			return nil, xerrors.Errorf("unsupported otp algorithm %q: %w", v, os.ErrInvalid)
+		}
+	}
+	if v := params.Get("digits"); len(v) > 0 {
+		if key.Digits, err = strconv.Atoi(v); err != nil || key.Digits < 6 || key.Digits > 10 {
+			return nil, xerrors.Errorf("invalid otp digits %q: %w", v, os.ErrInvalid)
+		}
+	}
+	if v := params.Get("period"); len(v) > 0 {
+		if key.Period, err = strconv.Atoi(v); err != nil || key.Period <= 0 {
+			return nil, xerrors.Errorf("invalid otp period %q: %w", v, os.ErrInvalid)
+		}
+	}
+	if key.Type == HOTP {
+		v := params.Get("counter")
+		if len(v) == 0 {
+			return nil, xerrors.Errorf("hotp uri must have a counter: %w", os.ErrInvalid)
+		}
+		if key.Counter, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, xerrors.Errorf("invalid hotp counter %q: %w", v, os.ErrInvalid)
+		}
+	}
+	return key, nil
+}
+
+// URI returns the key in the otpauth:// URI format.
+func (k *Key) URI() string {
+	params := url.Values{}
+	params.Set("secret", k.Secret)
+	if len(k.Issuer) > 0 {
+		params.Set("issuer", k.Issuer)
+	}
+	if k.Algorithm != "SHA1" {
+		params.Set("algorithm", k.Algorithm)
+	}
+	if k.Digits != 6 {
+		params.Set("digits", strconv.Itoa(k.Digits))
+	}
+	if k.Type == TOTP && k.Period != 30 {
+		params.Set("period", strconv.Itoa(k.Period))
+	}
+	if k.Type == HOTP {
+		params.Set("counter", strconv.FormatUint(k.Counter, 10))
+	}
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     k.Type,
+		Path:     "/" + k.Label,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
+
+// Code returns the one-time password at the input time for TOTP keys and for
+// the current counter value for HOTP keys. Callers must increment the Counter
+// for HOTP keys after using a password.
+func (k *Key) Code(now time.Time) (string, error) {
+	counter := k.Counter
+	if k.Type == TOTP {
+		counter = uint64(now.Unix() / int64(k.Period))
+	}
+	secret, err := k.secretBytes()
+	if err != nil {
+		return "", err
+	}
+	h := newHash(k.Algorithm)
+	if h == nil {
+		return "", xerrors.Errorf("unsupported otp algorithm %q: %w", k.Algorithm, os.ErrInvalid)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(h, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+	mod := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, value%mod), nil
+}
+
+// Remaining returns the time after which the TOTP password at the input time
+// expires.
+func (k *Key) Remaining(now time.Time) time.Duration {
+	if k.Type != TOTP {
+		return 0
+	}
+	period := int64(k.Period)
+	return time.Duration(period-now.Unix()%period) * time.Second
+}
+
+func (k *Key) secretBytes() ([]byte, error) {
+	if len(k.Secret) == 0 {
+		return nil, xerrors.Errorf("otp secret cannot be empty: %w", os.ErrInvalid)
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(k.Secret)
+	if err != nil {
+		return nil, xerrors.Errorf("otp secret is not in base32 encoding: %w", os.ErrInvalid)
+	}
+	return secret, nil
+}
+
+// normalizeSecret removes the spaces and padding from a base32 secret, which
+// are common in the secrets displayed by the websites.
+func normalizeSecret(s string) string {
+	s = strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	return strings.TrimRight(s, "=")
+}
+
+func newHash(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case "SHA1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA512":
+		return sha512.New
+	default:
+		return nil
+	}
+}
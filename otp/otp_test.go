@@ -0,0 +1,114 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package otp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func encodeSecret(secret string) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(secret))
+}
+
+// TestHOTP checks the test values from the RFC 4226 Appendix D.
+func TestHOTP(t *testing.T) {
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+	for counter, code := range want {
+		key := &Key{
+			Type:      HOTP,
+			Secret:    encodeSecret("12345678901234567890"),
+			Algorithm: "SHA1",
+			Digits:    6,
+			Counter:   uint64(counter),
+		}
+		got, err := key.Code(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != code {
+			t.Errorf("counter %d: got %q, want %q", counter, got, code)
+		}
+	}
+}
+
+// TestTOTP checks the test values from the RFC 6238 Appendix B.
+func TestTOTP(t *testing.T) {
+	secrets := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	testCases := []struct {
+		time      int64
+		algorithm string
+		want      string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+	for _, tc := range testCases {
+		key := &Key{
+			Type:      TOTP,
+			Secret:    encodeSecret(secrets[tc.algorithm]),
+			Algorithm: tc.algorithm,
+			Digits:    8,
+			Period:    30,
+		}
+		got, err := key.Code(time.Unix(tc.time, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s at %d: got %q, want %q", tc.algorithm, tc.time, got, tc.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	key, err := Parse("otpauth://hotp/ACME%20Co:john@example.com?secret=GEZDGNBVGY3TQOJQ&issuer=ACME%20Co&algorithm=sha256&digits=8&counter=42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Key{
+		Type:      HOTP,
+		Label:     "ACME Co:john@example.com",
+		Issuer:    "ACME Co",
+		Secret:    "GEZDGNBVGY3TQOJQ",
+		Algorithm: "SHA256",
+		Digits:    8,
+		Period:    30,
+		Counter:   42,
+	}
+	if *key != want {
+		t.Errorf("got %+v, want %+v", *key, want)
+	}
+
+	again, err := Parse(key.URI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *again != want {
+		t.Errorf("uri %q: got %+v, want %+v", key.URI(), *again, want)
+	}
+}
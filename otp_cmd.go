@@ -0,0 +1,172 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bvk/past/otp"
+	"github.com/bvk/past/store"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var otpCmd = &cobra.Command{
+	Use:   "otp [flags] <password-file>",
+	Short: "Prints the one-time password from a password-file.",
+	Long: `Prints the one-time password from a password-file.
+
+OTP secret is read from an otpauth:// URI line or from a "totp" key in the
+password-file. Counter in the otpauth:// URI is incremented and committed for
+the HOTP secrets.`,
+	RunE: cmdOTP,
+}
+
+var otpInsertCmd = &cobra.Command{
+	Use:   "insert [flags] <password-file> [<otpauth-uri>]",
+	Short: "Adds an OTP secret to an existing password-file.",
+	Long: `Adds an OTP secret to an existing password-file.
+
+Secret can be an otpauth:// URI or a base32 encoded TOTP secret. It is read from
+the terminal when not given as an argument.`,
+	RunE: cmdOTPInsert,
+}
+
+func init() {
+	flags := otpInsertCmd.Flags()
+	flags.Bool("force", false, "When true, existing OTP secret in the password-file is replaced.")
+
+	otpCmd.AddCommand(otpInsertCmd)
+}
+
+func cmdOTP(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+	if len(args) == 0 {
+		return xerrors.Errorf("password file argument is required: %w", os.ErrInvalid)
+	}
+	if len(args) > 1 {
+		return xerrors.Errorf("too many arguments: %w", os.ErrInvalid)
+	}
+
+	_, code, err := nextOTP(ps, args[0], time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Println(code)
+	return nil
+}
+
+func cmdOTPInsert(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+	if len(args) == 0 {
+		return xerrors.Errorf("password file argument is required: %w", os.ErrInvalid)
+	}
+	if len(args) > 2 {
+		return xerrors.Errorf("too many arguments: %w", os.ErrInvalid)
+	}
+	file := args[0]
+
+	force, err := flags.GetBool("force")
+	if err != nil {
+		return xerrors.Errorf("could not get --force value: %w", err)
+	}
+
+	var secret string
+	if len(args) == 2 {
+		secret = args[1]
+	} else {
+		if secret, err = getPassword("OTP secret or otpauth URI:"); err != nil {
+			return xerrors.Errorf("could not read otp secret: %w", err)
+		}
+	}
+
+	var key *otp.Key
+	if strings.HasPrefix(strings.TrimSpace(secret), "otpauth:") {
+		key, err = otp.Parse(secret)
+	} else {
+		key, err = otp.NewTOTP(file, secret)
+	}
+	if err != nil {
+		return xerrors.Errorf("could not parse otp secret: %w", err)
+	}
+
+	decrypted, err := ps.ReadFile(file)
+	if err != nil {
+		return xerrors.Errorf("could not read file %q: %w", file, err)
+	}
+	password, data := store.Parse(decrypted)
+	values := store.NewValues(data)
+	if old, _ := getOTPKey(values, file); old != nil && !force {
+		return xerrors.Errorf("password file %q already has an otp secret (use --force to replace): %w", file, os.ErrExist)
+	}
+	setOTPKey(values, key)
+
+	if err := ps.UpdateFile(file, store.Format(password, values.Bytes())); err != nil {
+		return xerrors.Errorf("could not update file %q: %w", file, err)
+	}
+	return nil
+}
+
+// getOTPKey returns the OTP key from an otpauth:// URI line or a "totp" key
+// in the password-file values. It returns nil if the values have no OTP key.
+func getOTPKey(values *store.Values, file string) (*otp.Key, error) {
+	// Values are separated from the keys by a colon, so "otpauth://..." lines
+	// are parsed as "otpauth" keys.
+	if v := values.Get("otpauth"); len(v) > 0 {
+		return otp.Parse("otpauth:" + v)
+	}
+	if v := values.Get("totp"); len(v) > 0 {
+		return otp.NewTOTP(file, v)
+	}
+	return nil, nil
+}
+
+// setOTPKey replaces the OTP key in the password-file values with an
+// otpauth:// URI line.
+func setOTPKey(values *store.Values, key *otp.Key) {
+	values.Del("totp")
+	values.Set("otpauth", strings.TrimPrefix(key.URI(), "otpauth:"))
+}
+
+// nextOTP returns the OTP key and the one-time password at the input time for
+// a password-file. HOTP counter is incremented in the password-file.
+func nextOTP(ps *store.PasswordStore, file string, now time.Time) (*otp.Key, string, error) {
+	decrypted, err := ps.ReadFile(file)
+	if err != nil {
+		return nil, "", xerrors.Errorf("could not read file %q: %w", file, err)
+	}
+	_, data := store.Parse(decrypted)
+	key, err := getOTPKey(store.NewValues(data), file)
+	if err != nil {
+		return nil, "", xerrors.Errorf("could not parse otp secret in %q: %w", file, err)
+	}
+	if key == nil {
+		return nil, "", xerrors.Errorf("password file %q doesn't have an otp secret: %w", file, os.ErrNotExist)
+	}
+	code, err := key.Code(now)
+	if err != nil {
+		return nil, "", xerrors.Errorf("could not compute one-time password: %w", err)
+	}
+	if key.Type == otp.HOTP {
+		// Only the otpauth line is updated, so that the rest of the password-file
+		// is kept unchanged.
+		key.Counter++
+		updated := store.SetValue(decrypted, "otpauth", strings.TrimPrefix(key.URI(), "otpauth:"))
+		if err := ps.UpdateCounter(file, updated); err != nil {
+			return nil, "", xerrors.Errorf("could not update hotp counter in %q: %w", file, err)
+		}
+	}
+	return key, code, nil
+}
@@ -0,0 +1,359 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bvk/past/store"
+
+	"golang.org/x/xerrors"
+)
+
+// ambiguousChars holds the characters that are easily confused with each other
+// when the passwords are read or typed by humans.
+var ambiguousChars = "0O1lI|`'\""
+
+// passwordClasses holds the characters for the named character classes in
+// the password rules. Space is left out from the special and ascii-printable
+// classes, so that generated passwords don't have leading or trailing spaces.
+var passwordClasses = map[string]string{
+	"upper":           "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"lower":           "abcdefghijklmnopqrstuvwxyz",
+	"digit":           "0123456789",
+	"special":         "-~!@#$%^&*_+=`|(){}[:;\"'<>,.?]",
+	"ascii-printable": NonSymbols + punctuation,
+	"unicode":         NonSymbols + punctuation,
+}
+
+// charRequirement holds the minimum number of characters required from a set
+// of characters.
+type charRequirement struct {
+	chars string
+	count int
+}
+
+// passwordPolicy holds the constraints for the generated passwords.
+type passwordPolicy struct {
+	// chars holds the characters allowed in the password.
+	chars string
+
+	// exclude holds the characters that are never used in the password.
+	exclude string
+
+	required []charRequirement
+
+	// minLength and maxLength are the password length limits when non-zero.
+	minLength int
+	maxLength int
+
+	// maxConsecutive is the maximum number of identical characters allowed in
+	// a row when non-zero.
+	maxConsecutive int
+}
+
+// require adds a requirement for at least count characters from a set of
+// characters.
+func (p *passwordPolicy) require(chars string, count int) {
+	if count > 0 {
+		p.required = append(p.required, charRequirement{chars: chars, count: count})
+	}
+}
+
+// allowed returns the characters that can be used in the password.
+func (p *passwordPolicy) allowed(chars string) string {
+	var out []byte
+	for i := 0; i < len(chars); i++ {
+		if strings.IndexByte(p.chars, chars[i]) >= 0 && strings.IndexByte(p.exclude, chars[i]) < 0 {
+			out = append(out, chars[i])
+		}
+	}
+	return string(out)
+}
+
+// length returns the password length for a requested length within the
+// policy limits.
+func (p *passwordPolicy) length(n int) int {
+	if p.minLength > 0 && n < p.minLength {
+		n = p.minLength
+	}
+	if p.maxLength > 0 && n > p.maxLength {
+		n = p.maxLength
+	}
+	return n
+}
+
+// generate returns a random password with the input length that satisfies
+// the policy. Required characters are picked first and shuffled with the rest
+// of the characters.
+func (p *passwordPolicy) generate(length int) (string, error) {
+	allowed := p.allowed(p.chars)
+	if len(allowed) == 0 {
+		return "", xerrors.Errorf("password policy doesn't allow any characters: %w", os.ErrInvalid)
+	}
+	total := 0
+	sets := make([]string, len(p.required))
+	for i, req := range p.required {
+		if sets[i] = p.allowed(req.chars); len(sets[i]) == 0 {
+			return "", xerrors.Errorf("password policy requires characters from %q that are not allowed: %w", req.chars, os.ErrInvalid)
+		}
+		total += req.count
+	}
+	if total > length {
+		return "", xerrors.Errorf("password policy requires %d characters in a %d character password: %w", total, length, os.ErrInvalid)
+	}
+
+	pick := func(chars string) (byte, error) {
+		n, err := randomInt(len(chars))
+		if err != nil {
+			return 0, err
+		}
+		return chars[n], nil
+	}
+	for attempt := 0; attempt < 100; attempt++ {
+		password := make([]byte, 0, length)
+		for i, req := range p.required {
+			for j := 0; j < req.count; j++ {
+				c, err := pick(sets[i])
+				if err != nil {
+					return "", err
+				}
+				password = append(password, c)
+			}
+		}
+		for len(password) < length {
+			c, err := pick(allowed)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, c)
+		}
+		for i := len(password) - 1; i > 0; i-- {
+			j, err := randomInt(i + 1)
+			if err != nil {
+				return "", err
+			}
+			password[i], password[j] = password[j], password[i]
+		}
+		if p.maxConsecutive > 0 {
+			breakRepeats(password, p.maxConsecutive)
+		}
+		if p.maxConsecutive == 0 || maxRepeats(password) <= p.maxConsecutive {
+			return string(password), nil
+		}
+	}
+	return "", xerrors.Errorf("could not generate a password that satisfies the policy: %w", os.ErrInvalid)
+}
+
+// check returns an error if a password doesn't satisfy the policy. Policies
+// only list the ascii characters, so other characters are not checked against
+// the allowed characters.
+func (p *passwordPolicy) check(password string) error {
+	n := len([]rune(password))
+	if p.minLength > 0 && n < p.minLength {
+		return xerrors.Errorf("password must have at least %d characters: %w", p.minLength, os.ErrInvalid)
+	}
+	if p.maxLength > 0 && n > p.maxLength {
+		return xerrors.Errorf("password cannot have more than %d characters: %w", p.maxLength, os.ErrInvalid)
+	}
+	if allowed := p.allowed(p.chars); len(allowed) > 0 {
+		for _, r := range password {
+			if r < 128 && strings.IndexRune(allowed, r) < 0 {
+				return xerrors.Errorf("password character %q is not allowed: %w", r, os.ErrInvalid)
+			}
+		}
+	}
+	for _, req := range p.required {
+		count := 0
+		for _, r := range password {
+			if strings.ContainsRune(req.chars, r) {
+				count++
+			}
+		}
+		if count < req.count {
+			return xerrors.Errorf("password must have at least %d of the characters %q: %w", req.count, req.chars, os.ErrInvalid)
+		}
+	}
+	if p.maxConsecutive > 0 && maxRepeats([]byte(password)) > p.maxConsecutive {
+		return xerrors.Errorf("password cannot have more than %d identical characters in a row: %w", p.maxConsecutive, os.ErrInvalid)
+	}
+	return nil
+}
+
+// checkPasswordRules verifies the password in a password-file content with the
+// password rules saved in its passwordrules value, if any.
+func checkPasswordRules(data []byte) error {
+	password, rest := store.Parse(data)
+	rules := store.NewValues(rest).Get("passwordrules")
+	if len(rules) == 0 {
+		return nil
+	}
+	p, err := parsePasswordRules(rules)
+	if err != nil {
+		return xerrors.Errorf("could not parse password rules %q: %w", rules, err)
+	}
+	if err := p.check(password); err != nil {
+		return xerrors.Errorf("password doesn't satisfy the password rules %q: %w", rules, err)
+	}
+	return nil
+}
+
+// breakRepeats swaps a later character into the runs of identical characters
+// that are longer than max, when possible.
+func breakRepeats(s []byte, max int) {
+	run := 0
+	for i := range s {
+		if i > 0 && s[i] == s[i-1] {
+			run++
+		} else {
+			run = 1
+		}
+		if run <= max {
+			continue
+		}
+		for j := i + 1; j < len(s); j++ {
+			if s[j] != s[i] {
+				s[i], s[j] = s[j], s[i]
+				run = 1
+				break
+			}
+		}
+	}
+}
+
+// maxRepeats returns the length of the longest run of identical characters.
+func maxRepeats(s []byte) int {
+	max, run := 0, 0
+	for i := range s {
+		if i > 0 && s[i] == s[i-1] {
+			run++
+		} else {
+			run = 1
+		}
+		if run > max {
+			max = run
+		}
+	}
+	return max
+}
+
+// parsePasswordRules returns the password policy for the password rules in
+// the Apple's passwordrules syntax, like
+//
+//	required: upper; required: digit, [-_]; allowed: lower; max-consecutive: 2
+//
+// Characters from all allowed and required classes are allowed and at least
+// one character is required from each required rule.
+func parsePasswordRules(rules string) (*passwordPolicy, error) {
+	p := new(passwordPolicy)
+	seen := make(map[byte]bool)
+	for _, rule := range strings.Split(rules, ";") {
+		rule = strings.TrimSpace(rule)
+		if len(rule) == 0 {
+			continue
+		}
+		colon := strings.Index(rule, ":")
+		if colon < 0 {
+			return nil, xerrors.Errorf("password rule %q has no value: %w", rule, os.ErrInvalid)
+		}
+		name := strings.ToLower(strings.TrimSpace(rule[:colon]))
+		value := strings.TrimSpace(rule[colon+1:])
+
+		switch name {
+		case "required", "allowed":
+			chars, err := parseRuleClasses(value)
+			if err != nil {
+				return nil, xerrors.Errorf("could not parse %s rule %q: %w", name, value, err)
+			}
+			for i := 0; i < len(chars); i++ {
+				seen[chars[i]] = true
+			}
+			if name == "required" {
+				p.require(chars, 1)
+			}
+
+		case "minlength", "maxlength", "max-consecutive":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, xerrors.Errorf("invalid %s value %q: %w", name, value, os.ErrInvalid)
+			}
+			switch name {
+			case "minlength":
+				p.minLength = n
+			case "maxlength":
+				p.maxLength = n
+			default:
+				p.maxConsecutive = n
+			}
+
+		default:
+			return nil, xerrors.Errorf("unsupported password rule %q: %w", name, os.ErrInvalid)
+		}
+	}
+
+	if p.minLength > 0 && p.maxLength > 0 && p.minLength > p.maxLength {
+		return nil, xerrors.Errorf("minlength %d cannot be more than maxlength %d: %w", p.minLength, p.maxLength, os.ErrInvalid)
+	}
+
+	var chars []byte
+	for c := range seen {
+		chars = append(chars, c)
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+	p.chars = string(chars)
+	return p, nil
+}
+
+// parseRuleClasses returns the characters in a comma separated list of named
+// character classes and custom classes like [-_].
+func parseRuleClasses(value string) (string, error) {
+	var chars string
+	for len(value) > 0 {
+		value = strings.TrimLeft(value, " ,")
+		if len(value) == 0 {
+			break
+		}
+		if value[0] == '[' {
+			// Closing bracket is part of the class when it is the first
+			// character, optionally after a hyphen.
+			start := 1
+			if strings.HasPrefix(value[start:], "-") {
+				start++
+			}
+			if strings.HasPrefix(value[start:], "]") {
+				start++
+			}
+			end := strings.IndexByte(value[start:], ']')
+			if end < 0 {
+				return "", xerrors.Errorf("custom character class is not closed: %w", os.ErrInvalid)
+			}
+			class := value[1 : start+end]
+			for i := 0; i < len(class); i++ {
+				if class[i] < ' ' || class[i] > '~' {
+					return "", xerrors.Errorf("only printable ascii characters are supported: %w", os.ErrInvalid)
+				}
+			}
+			chars += class
+			value = value[start+end+1:]
+			continue
+		}
+		end := strings.IndexAny(value, " ,")
+		if end < 0 {
+			end = len(value)
+		}
+		name := strings.ToLower(value[:end])
+		class, ok := passwordClasses[name]
+		if !ok {
+			return "", xerrors.Errorf("unknown character class %q: %w", name, os.ErrInvalid)
+		}
+		chars += class
+		value = value[end:]
+	}
+	if len(chars) == 0 {
+		return "", xerrors.Errorf("character classes cannot be empty: %w", os.ErrInvalid)
+	}
+	return chars, nil
+}
@@ -0,0 +1,413 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+// Package pgp implements an OpenPGP keyring in pure Go, so that password stores
+// can be used without the gpg binary. Encrypted data is compatible with the
+// password-files created by pass and gpg.
+package pgp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"golang.org/x/crypto/ripemd160"
+	"golang.org/x/xerrors"
+
+	"github.com/bvk/past/gpg"
+)
+
+// PassphraseFunc returns the passphrase for an encrypted secret key.
+type PassphraseFunc func(keyID string) (string, error)
+
+type Keyring struct {
+	files []string
+
+	passphrase PassphraseFunc
+
+	entities openpgp.EntityList
+}
+
+// DefaultKeyringFiles returns the legacy keyring files from the GnuPG home
+// directory. Keyrings in the keybox format used by GnuPG 2.1 and above must be
+// exported with `gpg --export-secret-keys` to be usable.
+func DefaultKeyringFiles() []string {
+	home := os.Getenv("GNUPGHOME")
+	if len(home) == 0 {
+		home = filepath.Join(os.Getenv("HOME"), ".gnupg")
+	}
+	return []string{
+		filepath.Join(home, "pubring.gpg"),
+		filepath.Join(home, "secring.gpg"),
+	}
+}
+
+// NewKeyring creates a keyring from the input OpenPGP keyring files in binary
+// or armored form. Input passphrase function is used to unlock encrypted
+// secret keys and can be nil.
+func NewKeyring(files []string, passphrase PassphraseFunc) (*Keyring, error) {
+	k := &Keyring{
+		files:      append([]string{}, files...),
+		passphrase: passphrase,
+	}
+	if err := k.Refresh(); err != nil {
+		return nil, xerrors.Errorf("could not load keyring files: %w", err)
+	}
+	if len(k.entities) == 0 {
+		return nil, xerrors.Errorf("could not find any keys in the keyring files %q: %w", files, os.ErrNotExist)
+	}
+	return k, nil
+}
+
+// Refresh reloads all keys from the keyring files. Files that do not exist
+// are ignored.
+func (k *Keyring) Refresh() error {
+	var entities openpgp.EntityList
+	indexMap := make(map[string]int)
+	for _, file := range k.files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return xerrors.Errorf("could not read keyring file %q: %w", file, err)
+		}
+		var list openpgp.EntityList
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+			list, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		} else {
+			list, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+		}
+		if err != nil {
+			return xerrors.Errorf("could not parse keyring file %q: %w", file, err)
+		}
+		// Entities with the secret keys are preferred over the public-only
+		// entities with the same fingerprint.
+		for _, e := range list {
+			fp := fingerprint(e.PrimaryKey)
+			if i, ok := indexMap[fp]; ok {
+				if entities[i].PrivateKey == nil && e.PrivateKey != nil {
+					entities[i] = e
+				}
+				continue
+			}
+			indexMap[fp] = len(entities)
+			entities = append(entities, e)
+		}
+	}
+	k.entities = entities
+	return nil
+}
+
+func (k *Keyring) Encrypt(data []byte, fps []string) ([]byte, error) {
+	var recipients []*openpgp.Entity
+	for _, fp := range fps {
+		e := k.findEntity(fp)
+		if e == nil {
+			return nil, xerrors.Errorf("could not find key %q in the keyring: %w", fp, os.ErrNotExist)
+		}
+		recipients = append(recipients, e)
+	}
+
+	// Password-files are not compressed by pass and past.
+	config := &packet.Config{DefaultCompressionAlgo: packet.CompressionNone}
+
+	var buffer bytes.Buffer
+	w, err := openpgp.Encrypt(&buffer, recipients, nil, nil, config)
+	if err != nil {
+		return nil, xerrors.Errorf("could not encrypt input data: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, xerrors.Errorf("could not encrypt input data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, xerrors.Errorf("could not finish encrypting input data: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (k *Keyring) Decrypt(data []byte) ([]byte, error) {
+	tried := make(map[uint64]bool)
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if symmetric || k.passphrase == nil {
+			return nil, xerrors.Errorf("secret key is encrypted: %w", os.ErrPermission)
+		}
+		for _, key := range keys {
+			if key.PrivateKey == nil || !key.PrivateKey.Encrypted || tried[key.PrivateKey.KeyId] {
+				continue
+			}
+			tried[key.PrivateKey.KeyId] = true
+			passphrase, err := k.passphrase(key.PrivateKey.KeyIdString())
+			if err != nil {
+				return nil, xerrors.Errorf("could not get passphrase: %w", err)
+			}
+			if err := key.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				continue
+			}
+			return nil, nil
+		}
+		return nil, xerrors.Errorf("could not unlock any secret key: %w", os.ErrPermission)
+	}
+
+	md, err := openpgp.ReadMessage(bytes.NewReader(unarmor(data)), k.entities, prompt, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("could not decrypt input data: %w", err)
+	}
+	decrypted, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, xerrors.Errorf("could not read decrypted data: %w", err)
+	}
+	if md.SignatureError != nil {
+		return nil, xerrors.Errorf("could not verify decrypted data: %w", md.SignatureError)
+	}
+	return decrypted, nil
+}
+
+// Sign returns a detached signature for the input data with the secret key
+// identified by the input key id, fingerprint or email address.
+func (k *Keyring) Sign(data []byte, key string) ([]byte, error) {
+	e := k.findEntity(key)
+	if e == nil || e.PrivateKey == nil {
+		return nil, xerrors.Errorf("could not find secret key %q in the keyring: %w", key, os.ErrNotExist)
+	}
+	if e.PrivateKey.Encrypted {
+		if k.passphrase == nil {
+			return nil, xerrors.Errorf("secret key %q is encrypted: %w", key, os.ErrPermission)
+		}
+		passphrase, err := k.passphrase(e.PrivateKey.KeyIdString())
+		if err != nil {
+			return nil, xerrors.Errorf("could not get passphrase: %w", err)
+		}
+		if err := e.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return nil, xerrors.Errorf("could not unlock secret key %q: %w", key, err)
+		}
+	}
+	var buffer bytes.Buffer
+	if err := openpgp.DetachSign(&buffer, e, bytes.NewReader(data), nil); err != nil {
+		return nil, xerrors.Errorf("could not sign with key %q: %w", key, err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Verify checks a detached signature for the input data and returns the
+// fingerprint of the signing key.
+func (k *Keyring) Verify(data, signature []byte) ([]string, error) {
+	e, err := openpgp.CheckDetachedSignature(k.entities, bytes.NewReader(data), bytes.NewReader(unarmor(signature)), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("could not verify signature: %w", err)
+	}
+	return []string{fingerprint(e.PrimaryKey)}, nil
+}
+
+// Recipients returns the key ids that can decrypt the encrypted input data.
+func (k *Keyring) Recipients(data []byte) ([]string, error) {
+	var ids []string
+	packets := packet.NewReader(bytes.NewReader(unarmor(data)))
+	for {
+		p, err := packets.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("could not parse encrypted data: %w", err)
+		}
+		ek, ok := p.(*packet.EncryptedKey)
+		if !ok {
+			break
+		}
+		ids = append(ids, fmt.Sprintf("%016X", ek.KeyId))
+	}
+	return ids, nil
+}
+
+// isValid returns true if a key is valid like in the gpg's trust model with
+// the secret keys as the ultimately trusted keys. Valid keys are not revoked or
+// expired and either have a secret key or a user id certified by one of the
+// secret keys.
+func (k *Keyring) isValid(e *openpgp.Entity, now time.Time) bool {
+	id := e.PrimaryIdentity()
+	if id == nil || id.SelfSignature == nil || e.Revoked(now) || id.Revoked(now) {
+		return false
+	}
+	if e.PrimaryKey.KeyExpired(id.SelfSignature, now) {
+		return false
+	}
+	if e.PrivateKey != nil {
+		return true
+	}
+	for _, ident := range e.Identities {
+		if ident.Revoked(now) {
+			continue
+		}
+		for _, sig := range ident.Signatures {
+			if sig.IssuerKeyId == nil || sig.SigExpired(now) {
+				continue
+			}
+			for _, signer := range k.entities {
+				if signer.PrivateKey == nil || signer.PrimaryKey.KeyId != *sig.IssuerKeyId || signer == e {
+					continue
+				}
+				if signer.PrimaryKey.VerifyUserIdSignature(ident.Name, e.PrimaryKey, sig) == nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (k *Keyring) PublicKeys() []*gpg.PublicKey {
+	now := time.Now()
+	var pks []*gpg.PublicKey
+	for _, e := range k.entities {
+		pkey := &gpg.PublicKey{
+			KeyID:       fmt.Sprintf("%016X", e.PrimaryKey.KeyId),
+			Fingerprint: fingerprint(e.PrimaryKey),
+			Trusted:     k.isValid(e, now),
+			CreatedAt:   e.PrimaryKey.CreationTime,
+			KeyLength:   bitLength(e.PrimaryKey),
+		}
+		if _, ok := e.EncryptionKey(now); ok {
+			pkey.CanEncrypt = true
+		}
+		if id := e.PrimaryIdentity(); id != nil {
+			pkey.UserDetail = id.Name
+			pkey.UserHash = userHash(id.Name)
+			if id.UserId != nil {
+				pkey.UserName = id.UserId.Name
+				pkey.UserEmail = id.UserId.Email
+			}
+			if sig := id.SelfSignature; sig != nil && sig.KeyLifetimeSecs != nil && *sig.KeyLifetimeSecs != 0 {
+				pkey.ExpiresAt = e.PrimaryKey.CreationTime.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second)
+			}
+		}
+		pks = append(pks, pkey)
+
+		for _, sub := range e.Subkeys {
+			skey := &gpg.PublicKey{
+				KeyID:       fmt.Sprintf("%016X", sub.PublicKey.KeyId),
+				Fingerprint: fingerprint(sub.PublicKey),
+				Subkey:      true,
+				Trusted:     pkey.Trusted,
+				CreatedAt:   sub.PublicKey.CreationTime,
+				KeyLength:   bitLength(sub.PublicKey),
+				UserDetail:  pkey.UserDetail,
+				UserHash:    pkey.UserHash,
+				UserName:    pkey.UserName,
+				UserEmail:   pkey.UserEmail,
+			}
+			if sig := sub.Sig; sig != nil {
+				skey.CanEncrypt = sig.FlagsValid && (sig.FlagEncryptCommunications || sig.FlagEncryptStorage)
+				if sig.KeyLifetimeSecs != nil && *sig.KeyLifetimeSecs != 0 {
+					skey.ExpiresAt = sub.PublicKey.CreationTime.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second)
+				}
+			}
+			pks = append(pks, skey)
+		}
+	}
+	return pks
+}
+
+func (k *Keyring) SecretKeys() []*gpg.SecretKey {
+	secrets := make(map[string]bool)
+	for _, e := range k.entities {
+		if e.PrivateKey != nil {
+			secrets[fingerprint(e.PrimaryKey)] = true
+		}
+		for _, sub := range e.Subkeys {
+			if sub.PrivateKey != nil {
+				secrets[fingerprint(sub.PublicKey)] = true
+			}
+		}
+	}
+	var sks []*gpg.SecretKey
+	for _, pk := range k.PublicKeys() {
+		if !secrets[pk.Fingerprint] {
+			continue
+		}
+		sks = append(sks, &gpg.SecretKey{
+			KeyID:       pk.KeyID,
+			Fingerprint: pk.Fingerprint,
+			UserHash:    pk.UserHash,
+			CanEncrypt:  pk.CanEncrypt,
+			Trusted:     pk.Trusted,
+			Subkey:      pk.Subkey,
+			KeyLength:   pk.KeyLength,
+			CreatedAt:   pk.CreatedAt,
+			ExpiresAt:   pk.ExpiresAt,
+			UserDetail:  pk.UserDetail,
+			UserName:    pk.UserName,
+			UserEmail:   pk.UserEmail,
+		})
+	}
+	return sks
+}
+
+// findEntity returns the entity with a matching fingerprint, key id or email
+// address for its primary key or any of its subkeys.
+func (k *Keyring) findEntity(id string) *openpgp.Entity {
+	matches := func(pk *packet.PublicKey) bool {
+		return strings.EqualFold(fingerprint(pk), id) ||
+			strings.EqualFold(fmt.Sprintf("%016X", pk.KeyId), id) ||
+			strings.EqualFold(fmt.Sprintf("%08X", uint32(pk.KeyId)), id)
+	}
+	for _, e := range k.entities {
+		if matches(e.PrimaryKey) {
+			return e
+		}
+		for _, sub := range e.Subkeys {
+			if matches(sub.PublicKey) {
+				return e
+			}
+		}
+		for _, ident := range e.Identities {
+			if ident.UserId != nil && strings.EqualFold(ident.UserId.Email, id) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func fingerprint(pk *packet.PublicKey) string {
+	return fmt.Sprintf("%X", pk.Fingerprint)
+}
+
+func bitLength(pk *packet.PublicKey) int {
+	n, err := pk.BitLength()
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// userHash returns the user id hash in the same form as gpg's --with-colons
+// output.
+func userHash(uid string) string {
+	h := ripemd160.New()
+	h.Write([]byte(uid))
+	return fmt.Sprintf("%X", h.Sum(nil))
+}
+
+// unarmor returns the binary form of the input data if it is armored.
+func unarmor(data []byte) []byte {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		return data
+	}
+	block, err := armor.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data
+	}
+	decoded, err := ioutil.ReadAll(block.Body)
+	if err != nil {
+		return data
+	}
+	return decoded
+}
@@ -0,0 +1,416 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package qr
+
+import (
+	"bytes"
+	"math/bits"
+	"os"
+
+	"golang.org/x/xerrors"
+)
+
+// matrix holds the modules of a QR code as rows of dark (true) and light
+// (false) modules.
+type matrix [][]bool
+
+func (m matrix) transpose() matrix {
+	t := make(matrix, len(m))
+	for y := range m {
+		t[y] = make([]bool, len(m))
+		for x := range m {
+			t[y][x] = m[x][y]
+		}
+	}
+	return t
+}
+
+// decodeMatrix returns the data encoded in the QR code modules.
+func decodeMatrix(m matrix) ([]byte, error) {
+	size := len(m)
+	if size < 21 || size > 177 || size%4 != 1 {
+		return nil, xerrors.Errorf("invalid qr code size %d: %w", size, os.ErrInvalid)
+	}
+	version := (size - 17) / 4
+
+	level, mask, err := m.formatInfo()
+	if err != nil {
+		return nil, err
+	}
+	if version >= 7 {
+		v, err := m.versionInfo()
+		if err != nil {
+			return nil, err
+		}
+		if v != version {
+			return nil, xerrors.Errorf("version %d doesn't match the qr code size %d: %w", v, size, os.ErrInvalid)
+		}
+	}
+
+	codewords := m.codewords(version, mask)
+	data, err := correctBlocks(codewords, version, level)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSegments(data, version)
+}
+
+// formatInfo returns the error correction level index and the mask pattern
+// from the best of the two copies of the format information.
+func (m matrix) formatInfo() (int, int, error) {
+	size := len(m)
+	var copy1, copy2 int
+	set := func(v *int, bit int, dark bool) {
+		if dark {
+			*v |= 1 << uint(bit)
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		set(&copy1, i, m[i][8])
+	}
+	set(&copy1, 6, m[7][8])
+	set(&copy1, 7, m[8][8])
+	set(&copy1, 8, m[8][7])
+	for i := 9; i < 15; i++ {
+		set(&copy1, i, m[8][14-i])
+	}
+	for i := 0; i < 8; i++ {
+		set(&copy2, i, m[8][size-1-i])
+	}
+	for i := 8; i < 15; i++ {
+		set(&copy2, i, m[size-15+i][8])
+	}
+
+	best, bestDistance := -1, 4
+	for data := 0; data < 32; data++ {
+		rem := data
+		for i := 0; i < 10; i++ {
+			rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+		}
+		code := (data<<10 | rem) ^ 0x5412
+		for _, c := range []int{copy1, copy2} {
+			if d := bits.OnesCount(uint(code ^ c)); d < bestDistance {
+				best, bestDistance = data, d
+			}
+		}
+	}
+	if best < 0 {
+		return 0, 0, xerrors.Errorf("could not read the format information: %w", os.ErrInvalid)
+	}
+	return levelIndex[best>>3], best & 7, nil
+}
+
+// versionInfo returns the version from the best of the two copies of the
+// version information.
+func (m matrix) versionInfo() (int, error) {
+	size := len(m)
+	var copy1, copy2 int
+	for i := 0; i < 18; i++ {
+		a, b := size-11+i%3, i/3
+		if m[b][a] {
+			copy1 |= 1 << uint(i)
+		}
+		if m[a][b] {
+			copy2 |= 1 << uint(i)
+		}
+	}
+
+	best, bestDistance := -1, 4
+	for version := 7; version <= 40; version++ {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+		}
+		code := version<<12 | rem
+		for _, c := range []int{copy1, copy2} {
+			if d := bits.OnesCount(uint(code ^ c)); d < bestDistance {
+				best, bestDistance = version, d
+			}
+		}
+	}
+	if best < 0 {
+		return 0, xerrors.Errorf("could not read the version information: %w", os.ErrInvalid)
+	}
+	return best, nil
+}
+
+// functionModules returns the modules used by the finder, timing and
+// alignment patterns and the format and version information in a version.
+func functionModules(version int) matrix {
+	size := version*4 + 17
+	fn := make(matrix, size)
+	for y := range fn {
+		fn[y] = make([]bool, size)
+	}
+	fill := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				fn[y][x] = true
+			}
+		}
+	}
+
+	// Timing patterns.
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+
+	// Finder patterns with the separators and the format information.
+	fill(0, 0, 9, 9)
+	fill(size-8, 0, 8, 9)
+	fill(0, size-8, 9, 8)
+
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			fill(x-2, y-2, 5, 5)
+		}
+	}
+
+	if version >= 7 {
+		fill(size-11, 0, 3, 6)
+		fill(0, size-11, 6, 3)
+	}
+	return fn
+}
+
+// masked returns true if the module at a column and row is inverted by a
+// mask pattern.
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// codewords returns the interleaved codewords from the modules, which are
+// placed in two column wide strips from the right, alternating upwards and
+// downwards.
+func (m matrix) codewords(version, mask int) []byte {
+	size := len(m)
+	fn := functionModules(version)
+	codewords := make([]byte, numRawCodewords(version))
+	i := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < size; vert++ {
+			y := vert
+			if upward {
+				y = size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if fn[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				if m[y][x] != masked(mask, x, y) {
+					codewords[i/8] |= 0x80 >> uint(i%8)
+				}
+				i++
+			}
+		}
+	}
+	return codewords
+}
+
+// correctBlocks de-interleaves the codewords into the blocks, corrects the
+// errors in each block and returns the data codewords.
+func correctBlocks(codewords []byte, version, level int) ([]byte, error) {
+	nblocks := numBlocks[level][version]
+	nec := numECCodewords[level][version]
+	nshort := nblocks - len(codewords)%nblocks
+	shortLen := len(codewords) / nblocks
+
+	// Short blocks have one less data codeword than the long blocks, which is
+	// skipped in the interleaving.
+	blocks := make([][]byte, nblocks)
+	for j := range blocks {
+		blocks[j] = make([]byte, shortLen+1)
+	}
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j := 0; j < nblocks; j++ {
+			if i == shortLen-nec && j < nshort {
+				continue
+			}
+			blocks[j][i] = codewords[k]
+			k++
+		}
+	}
+
+	var data []byte
+	for j, block := range blocks {
+		if j < nshort {
+			block = append(block[:shortLen-nec], block[shortLen-nec+1:]...)
+		}
+		if err := correctErrors(block, nec); err != nil {
+			return nil, xerrors.Errorf("could not correct errors in block %d: %w", j, err)
+		}
+		data = append(data, block[:len(block)-nec]...)
+	}
+	return data, nil
+}
+
+// bitReader reads big-endian bit fields from the data codewords.
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) remaining() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if n > r.remaining() {
+		return 0, xerrors.Errorf("unexpected end of qr code data: %w", os.ErrInvalid)
+	}
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := r.data[r.pos/8] >> uint(7-r.pos%8) & 1
+		v = v<<1 | int(bit)
+		r.pos++
+	}
+	return v, nil
+}
+
+const alphanumericChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// decodeSegments returns the data from the numeric, alphanumeric and byte
+// mode segments. Extended channel interpretations are ignored and byte mode
+// data is returned as is, which is usually UTF-8 text.
+func decodeSegments(data []byte, version int) ([]byte, error) {
+	// Number of bits in the character count for each mode depends on the
+	// version range.
+	countBits := func(small, medium, large int) int {
+		switch {
+		case version <= 9:
+			return small
+		case version <= 26:
+			return medium
+		default:
+			return large
+		}
+	}
+
+	var out bytes.Buffer
+	r := &bitReader{data: data}
+	for r.remaining() >= 4 {
+		mode, _ := r.read(4)
+		switch mode {
+		case 0: // Terminator.
+			return out.Bytes(), nil
+
+		case 1: // Numeric.
+			count, err := r.read(countBits(10, 12, 14))
+			if err != nil {
+				return nil, err
+			}
+			for ; count > 0; count -= 3 {
+				n, digits := 10, 3
+				if count == 2 {
+					n, digits = 7, 2
+				} else if count == 1 {
+					n, digits = 4, 1
+				}
+				v, err := r.read(n)
+				if err != nil {
+					return nil, err
+				}
+				s := []byte{byte('0' + v/100%10), byte('0' + v/10%10), byte('0' + v%10)}
+				out.Write(s[3-digits:])
+			}
+
+		case 2: // Alphanumeric.
+			count, err := r.read(countBits(9, 11, 13))
+			if err != nil {
+				return nil, err
+			}
+			for ; count > 1; count -= 2 {
+				v, err := r.read(11)
+				if err != nil {
+					return nil, err
+				}
+				if v/45 >= len(alphanumericChars) {
+					return nil, xerrors.Errorf("invalid alphanumeric data: %w", os.ErrInvalid)
+				}
+				out.WriteByte(alphanumericChars[v/45])
+				out.WriteByte(alphanumericChars[v%45])
+			}
+			if count == 1 {
+				v, err := r.read(6)
+				if err != nil {
+					return nil, err
+				}
+				if v >= len(alphanumericChars) {
+					return nil, xerrors.Errorf("invalid alphanumeric data: %w", os.ErrInvalid)
+				}
+				out.WriteByte(alphanumericChars[v])
+			}
+
+		case 4: // Byte.
+			count, err := r.read(countBits(8, 16, 16))
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < count; i++ {
+				v, err := r.read(8)
+				if err != nil {
+					return nil, err
+				}
+				out.WriteByte(byte(v))
+			}
+
+		case 7: // Extended channel interpretation.
+			v, err := r.read(8)
+			if err != nil {
+				return nil, err
+			}
+			if v&0x80 != 0 {
+				n := 8
+				if v&0xc0 == 0xc0 {
+					n = 16
+				}
+				if _, err := r.read(n); err != nil {
+					return nil, err
+				}
+			}
+
+		case 3: // Structured append header.
+			if _, err := r.read(16); err != nil {
+				return nil, err
+			}
+
+		case 5: // FNC1 in first position.
+
+		case 9: // FNC1 in second position.
+			if _, err := r.read(8); err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, xerrors.Errorf("unsupported qr code data mode %d: %w", mode, os.ErrInvalid)
+		}
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,443 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package qr
+
+import (
+	"image"
+	"math"
+	"os"
+	"sort"
+
+	"golang.org/x/xerrors"
+)
+
+// bitmap holds the dark (true) and light (false) pixels of an image.
+type bitmap struct {
+	width, height int
+	dark          []bool
+}
+
+func (b *bitmap) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.width || y >= b.height {
+		return false
+	}
+	return b.dark[y*b.width+x]
+}
+
+// binarize converts an image into dark and light pixels with a global
+// threshold chosen with Otsu's method. Transparent pixels are light.
+func binarize(img image.Image) *bitmap {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	lum := make([]uint8, w*h)
+	var hist [256]int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			// Colors are alpha-premultiplied, so they are composited over white.
+			l := (19595*r+38470*g+7471*b+1<<15)>>24 + (0xffff-a)>>8
+			if l > 255 {
+				l = 255
+			}
+			lum[y*w+x] = uint8(l)
+			hist[l]++
+		}
+	}
+
+	total, sum := w*h, 0
+	for i, n := range hist {
+		sum += i * n
+	}
+	threshold, best := 0, 0.0
+	sumBelow, countBelow := 0, 0
+	for i, n := range hist {
+		countBelow += n
+		sumBelow += i * n
+		countAbove := total - countBelow
+		if countBelow == 0 || countAbove == 0 {
+			continue
+		}
+		meanBelow := float64(sumBelow) / float64(countBelow)
+		meanAbove := float64(sum-sumBelow) / float64(countAbove)
+		v := float64(countBelow) * float64(countAbove) * (meanBelow - meanAbove) * (meanBelow - meanAbove)
+		if v > best {
+			threshold, best = i, v
+		}
+	}
+
+	bm := &bitmap{width: w, height: h, dark: make([]bool, w*h)}
+	for i, l := range lum {
+		bm.dark[i] = int(l) <= threshold
+	}
+	return bm
+}
+
+type point struct {
+	x, y float64
+}
+
+func distance(a, b point) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+// finder is a candidate for the center of a finder pattern.
+type finder struct {
+	point
+	module float64
+	count  int
+}
+
+// isFinderRatio returns true if the run lengths of dark, light, dark, light
+// and dark pixels are in the 1:1:3:1:1 ratio of a finder pattern.
+func isFinderRatio(counts [5]int) bool {
+	total := 0
+	for _, c := range counts {
+		if c == 0 {
+			return false
+		}
+		total += c
+	}
+	if total < 7 {
+		return false
+	}
+	module := float64(total) / 7
+	tolerance := module / 2
+	return math.Abs(module-float64(counts[0])) < tolerance &&
+		math.Abs(module-float64(counts[1])) < tolerance &&
+		math.Abs(3*module-float64(counts[2])) < 3*tolerance &&
+		math.Abs(module-float64(counts[3])) < tolerance &&
+		math.Abs(module-float64(counts[4])) < tolerance
+}
+
+func runsCenter(counts [5]int, end int) float64 {
+	return float64(end-counts[4]-counts[3]) - float64(counts[2])/2
+}
+
+// crossCheck counts the finder pattern runs through a center point along a
+// direction and returns the refined center position along that direction and
+// the total length of the runs.
+func (b *bitmap) crossCheck(cx, cy, dx, dy, maxCount, expectedTotal int) (float64, int, bool) {
+	var counts [5]int
+	x, y := cx, cy
+	for ; b.at(x, y) && b.inside(x, y); x, y = x-dx, y-dy {
+		counts[2]++
+	}
+	for ; !b.at(x, y) && b.inside(x, y) && counts[1] <= maxCount; x, y = x-dx, y-dy {
+		counts[1]++
+	}
+	for ; b.at(x, y) && b.inside(x, y) && counts[0] <= maxCount; x, y = x-dx, y-dy {
+		counts[0]++
+	}
+	if counts[1] > maxCount || counts[0] > maxCount {
+		return 0, 0, false
+	}
+	x, y = cx+dx, cy+dy
+	for ; b.at(x, y) && b.inside(x, y); x, y = x+dx, y+dy {
+		counts[2]++
+	}
+	for ; !b.at(x, y) && b.inside(x, y) && counts[3] <= maxCount; x, y = x+dx, y+dy {
+		counts[3]++
+	}
+	for ; b.at(x, y) && b.inside(x, y) && counts[4] <= maxCount; x, y = x+dx, y+dy {
+		counts[4]++
+	}
+	if counts[3] > maxCount || counts[4] > maxCount {
+		return 0, 0, false
+	}
+
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	if 5*abs(total-expectedTotal) >= 2*expectedTotal || !isFinderRatio(counts) {
+		return 0, 0, false
+	}
+	end := x*dx + y*dy
+	return runsCenter(counts, end), total, true
+}
+
+func (b *bitmap) inside(x, y int) bool {
+	return x >= 0 && y >= 0 && x < b.width && y < b.height
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// findFinders scans the rows of the bitmap for the finder patterns, which are
+// confirmed by scanning the column and the row through their centers.
+func (b *bitmap) findFinders() []*finder {
+	var finders []*finder
+	check := func(counts [5]int, y, end int) bool {
+		total := counts[0] + counts[1] + counts[2] + counts[3] + counts[4]
+		cx := runsCenter(counts, end)
+		cy, vtotal, ok := b.crossCheck(int(cx), y, 0, 1, counts[2], total)
+		if !ok {
+			return false
+		}
+		cx, htotal, ok := b.crossCheck(int(cx), int(cy), 1, 0, counts[2], total)
+		if !ok {
+			return false
+		}
+		module := float64(vtotal+htotal) / 14
+		for _, f := range finders {
+			if math.Abs(f.x-cx) <= module && math.Abs(f.y-cy) <= module && math.Abs(f.module-module) <= math.Max(1, f.module/2) {
+				n := float64(f.count)
+				f.x = (f.x*n + cx) / (n + 1)
+				f.y = (f.y*n + cy) / (n + 1)
+				f.module = (f.module*n + module) / (n + 1)
+				f.count++
+				return true
+			}
+		}
+		finders = append(finders, &finder{point: point{cx, cy}, module: module, count: 1})
+		return true
+	}
+
+	for y := 0; y < b.height; y++ {
+		var counts [5]int
+		state := 0
+		for x := 0; x < b.width; x++ {
+			if b.at(x, y) {
+				if state%2 == 1 {
+					state++
+				}
+				counts[state]++
+				continue
+			}
+			if state%2 == 1 {
+				counts[state]++
+				continue
+			}
+			if state == 0 && counts[0] == 0 {
+				continue
+			}
+			if state < 4 {
+				state++
+				counts[state]++
+				continue
+			}
+			if isFinderRatio(counts) && check(counts, y, x) {
+				counts, state = [5]int{}, 0
+				continue
+			}
+			counts = [5]int{counts[2], counts[3], counts[4], 1, 0}
+			state = 3
+		}
+		if state == 4 && isFinderRatio(counts) {
+			check(counts, y, b.width)
+		}
+	}
+	return finders
+}
+
+// selectFinders returns the top-left, top-right and bottom-left finder
+// patterns from the candidates.
+func selectFinders(candidates []*finder) (tl, tr, bl point, module float64, err error) {
+	if len(candidates) < 3 {
+		err = xerrors.Errorf("could not find the finder patterns: %w", os.ErrNotExist)
+		return
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].count > candidates[j].count })
+	if len(candidates) > 10 {
+		candidates = candidates[:10]
+	}
+
+	// Choose the three candidates with the most similar sizes that form an
+	// isosceles right triangle.
+	var best [3]*finder
+	bestScore := math.Inf(1)
+	for i := 0; i < len(candidates); i++ {
+		for j := i + 1; j < len(candidates); j++ {
+			for k := j + 1; k < len(candidates); k++ {
+				a, b, c := candidates[i], candidates[j], candidates[k]
+				sides := []float64{distance(a.point, b.point), distance(b.point, c.point), distance(a.point, c.point)}
+				sort.Float64s(sides)
+				if sides[0] < 7*a.module {
+					continue
+				}
+				avg := (a.module + b.module + c.module) / 3
+				sizeError := (math.Abs(a.module-avg) + math.Abs(b.module-avg) + math.Abs(c.module-avg)) / avg
+				shapeError := math.Abs(sides[0]-sides[1])/sides[1] + math.Abs(sides[2]-math.Hypot(sides[0], sides[1]))/sides[2]
+				if score := sizeError + shapeError; score < bestScore {
+					best, bestScore = [3]*finder{a, b, c}, score
+				}
+			}
+		}
+	}
+	if best[0] == nil || bestScore > 1 {
+		err = xerrors.Errorf("could not find the finder patterns: %w", os.ErrNotExist)
+		return
+	}
+
+	// Top-left finder is opposite to the longest side. Other two are ordered
+	// with the cross product, so that mirrored images are decoded as is.
+	a, b, c := best[0].point, best[1].point, best[2].point
+	ab, bc, ac := distance(a, b), distance(b, c), distance(a, c)
+	switch {
+	case bc >= ab && bc >= ac:
+		tl, tr, bl = a, b, c
+	case ac >= ab && ac >= bc:
+		tl, tr, bl = b, a, c
+	default:
+		tl, tr, bl = c, a, b
+	}
+	if (tr.x-tl.x)*(bl.y-tl.y)-(tr.y-tl.y)*(bl.x-tl.x) < 0 {
+		tr, bl = bl, tr
+	}
+	module = (best[0].module + best[1].module + best[2].module) / 3
+	return tl, tr, bl, module, nil
+}
+
+// moduleAlong returns the module size of a finder pattern measured along a
+// direction through its center, which is seven modules wide. It returns zero
+// if the finder pattern could not be measured.
+func (b *bitmap) moduleAlong(c, dir point) float64 {
+	n := math.Hypot(dir.x, dir.y)
+	ux, uy := dir.x/n, dir.y/n
+	width := 0.0
+	for _, sign := range []float64{1, -1} {
+		// Runs from the center are dark, light and dark for three and half
+		// modules.
+		want, runs := true, 0
+		t := 0.0
+		for ; runs < 3 && t < 1e4; t++ {
+			x, y := int(math.Floor(c.x+sign*t*ux)), int(math.Floor(c.y+sign*t*uy))
+			if !b.inside(x, y) {
+				return 0
+			}
+			if b.at(x, y) != want {
+				want = !want
+				runs++
+			}
+		}
+		width += t - 1
+	}
+	return width / 7
+}
+
+// transform is a perspective transformation from the module coordinates to
+// the image coordinates.
+type transform [8]float64
+
+func (t *transform) apply(u, v float64) point {
+	d := t[6]*u + t[7]*v + 1
+	return point{(t[0]*u + t[1]*v + t[2]) / d, (t[3]*u + t[4]*v + t[5]) / d}
+}
+
+// newTransform returns the perspective transformation that maps four points
+// in the module coordinates to four points in the image coordinates.
+func newTransform(src, dst [4]point) (*transform, error) {
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		u, v, x, y := src[i].x, src[i].y, dst[i].x, dst[i].y
+		a[2*i] = [9]float64{u, v, 1, 0, 0, 0, -u * x, -v * x, x}
+		a[2*i+1] = [9]float64{0, 0, 0, u, v, 1, -u * y, -v * y, y}
+	}
+	// Gaussian elimination with partial pivoting.
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, xerrors.Errorf("qr code corners are degenerate: %w", os.ErrInvalid)
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+	var t transform
+	for i := range t {
+		t[i] = a[i][8] / a[i][i]
+	}
+	return &t, nil
+}
+
+// findAlignment searches for the alignment pattern around an estimated center
+// and returns the best match, if any. Vectors dx and dy are the sizes of a
+// module along the rows and the columns of the QR code.
+func (b *bitmap) findAlignment(estimate, dx, dy point, radius float64) (point, bool) {
+	step := math.Max(1, math.Hypot(dx.x, dx.y)/3)
+	best, bestScore := estimate, 0
+	for oy := -radius; oy <= radius; oy += step {
+		for ox := -radius; ox <= radius; ox += step {
+			c := point{estimate.x + ox, estimate.y + oy}
+			score := 0
+			for j := -2; j <= 2; j++ {
+				for i := -2; i <= 2; i++ {
+					ring := i
+					if ring < 0 {
+						ring = -ring
+					}
+					if r := abs(j); r > ring {
+						ring = r
+					}
+					x := c.x + float64(i)*dx.x + float64(j)*dy.x
+					y := c.y + float64(i)*dx.y + float64(j)*dy.y
+					if b.at(int(x), int(y)) == (ring != 1) {
+						score++
+					}
+				}
+			}
+			if score > bestScore {
+				best, bestScore = c, score
+			}
+		}
+	}
+	return best, bestScore >= 23
+}
+
+// sample reads the modules of a QR code with a size from the bitmap using
+// the finder pattern centers and the bottom-right alignment pattern, if
+// requested.
+func (b *bitmap) sample(tl, tr, bl point, size int, alignment bool) (matrix, error) {
+	n := float64(size)
+	d := n - 7
+	dx := point{(tr.x - tl.x) / d, (tr.y - tl.y) / d}
+	dy := point{(bl.x - tl.x) / d, (bl.y - tl.y) / d}
+
+	// Fourth corner is the bottom-right alignment pattern when available,
+	// which corrects the perspective distortion, otherwise the transformation
+	// is affine.
+	src := [4]point{{3.5, 3.5}, {n - 3.5, 3.5}, {3.5, n - 3.5}, {n - 3.5, n - 3.5}}
+	dst := [4]point{tl, tr, bl, {tr.x + bl.x - tl.x, tr.y + bl.y - tl.y}}
+	if alignment && size > 21 {
+		estimate := point{tl.x + (n-10)*(dx.x+dy.x), tl.y + (n-10)*(dx.y+dy.y)}
+		// Search area is increased gradually, because the estimate is farther
+		// from the alignment pattern with more perspective distortion.
+		module := math.Max(math.Hypot(dx.x, dx.y), math.Hypot(dy.x, dy.y))
+		for _, radius := range []float64{4 * module, 8 * module, 16 * module} {
+			if c, ok := b.findAlignment(estimate, dx, dy, radius); ok {
+				src[3], dst[3] = point{n - 6.5, n - 6.5}, c
+				break
+			}
+		}
+	}
+	t, err := newTransform(src, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(matrix, size)
+	for y := 0; y < size; y++ {
+		m[y] = make([]bool, size)
+		for x := 0; x < size; x++ {
+			p := t.apply(float64(x)+0.5, float64(y)+0.5)
+			m[y][x] = b.at(int(math.Floor(p.x)), int(math.Floor(p.y)))
+		}
+	}
+	return m, nil
+}
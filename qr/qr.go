@@ -0,0 +1,102 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+// Package qr implements a decoder for the QR codes in images, which is enough
+// to read the QR codes from the screenshots and the photos of the screens
+// with the two-factor authentication secrets.
+//
+// Decoder finds a single QR code with the three finder patterns, corrects the
+// perspective with the bottom-right alignment pattern and decodes the
+// numeric, alphanumeric and byte mode data. Kanji mode is not supported.
+package qr
+
+import (
+	"image"
+	"math"
+	"os"
+
+	"golang.org/x/xerrors"
+)
+
+// Decode returns the data from a QR code in the image.
+func Decode(img image.Image) ([]byte, error) {
+	b := binarize(img)
+	tl, tr, bl, module, err := selectFinders(b.findFinders())
+	if err != nil {
+		return nil, err
+	}
+
+	// Module size is measured along the sides of the QR code, because the
+	// finder patterns are measured along the rows and columns of the image.
+	if m := b.moduleSizeAlong(tl, tr, bl); m > 0 {
+		module = m
+	}
+
+	// Size is estimated from the distances between the finder patterns, so
+	// the nearest valid sizes are also tried. Version information, when
+	// available, gives the exact size.
+	estimate := (distance(tl, tr)+distance(tl, bl))/(2*module) + 7
+	version := int(math.Round((estimate - 17) / 4))
+	versions := []int{version, version - 1, version + 1}
+	tried := make(map[int]bool)
+	var firstErr error
+	for len(versions) > 0 {
+		v := versions[0]
+		versions = versions[1:]
+		if v < 1 || v > 40 || tried[v] {
+			continue
+		}
+		tried[v] = true
+		for _, alignment := range []bool{true, false} {
+			m, err := b.sample(tl, tr, bl, v*4+17, alignment)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			if v >= 7 {
+				if info, err := m.versionInfo(); err == nil && info != v {
+					versions = append([]int{info}, versions...)
+					break
+				}
+			}
+			data, err := decodeMatrix(m)
+			if err != nil {
+				// Mirrored QR codes have the top-right and bottom-left finder
+				// patterns swapped.
+				if d, terr := decodeMatrix(m.transpose()); terr == nil {
+					return d, nil
+				}
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			return data, nil
+		}
+	}
+	if firstErr == nil {
+		firstErr = os.ErrNotExist
+	}
+	return nil, xerrors.Errorf("could not decode qr code: %w", firstErr)
+}
+
+// moduleSizeAlong returns the average module size of the finder patterns
+// measured along the sides of the QR code. It returns zero if any of the
+// finder patterns could not be measured.
+func (b *bitmap) moduleSizeAlong(tl, tr, bl point) float64 {
+	sizes := []float64{
+		b.moduleAlong(tl, point{tr.x - tl.x, tr.y - tl.y}),
+		b.moduleAlong(tl, point{bl.x - tl.x, bl.y - tl.y}),
+		b.moduleAlong(tr, point{tl.x - tr.x, tl.y - tr.y}),
+		b.moduleAlong(bl, point{tl.x - bl.x, tl.y - bl.y}),
+	}
+	sum := 0.0
+	for _, s := range sizes {
+		if s == 0 {
+			return 0
+		}
+		sum += s
+	}
+	return sum / float64(len(sizes))
+}
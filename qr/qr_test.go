@@ -0,0 +1,98 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package qr
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// migrationURI is the data in the testdata/migration.png file.
+const migrationURI = "otpauth-migration://offline?data=CjUKFDEyMzQ1Njc4OTAxMjM0NTY3ODkwEg9ib2JAZXhhbXBsZS5jb20aBkdpdEh1YiABKAEwAgoiCgphYmNkZWZnaGlqEgpDb3JwOmNhcm9sGgRDb3JwMAE4BRAB"
+
+func readImage(t *testing.T, name string) image.Image {
+	t.Helper()
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// rotate returns the image rotated by 90 degrees clockwise.
+func rotate(img image.Image) image.Image {
+	b := img.Bounds()
+	out := image.NewGray(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.Set(b.Max.Y-1-y, x-b.Min.X, img.At(x, y))
+		}
+	}
+	return out
+}
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		file   string
+		rotate bool
+		want   string
+	}{
+		{
+			file: "totp.png",
+			want: "otpauth://totp/Example:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Example",
+		},
+		{
+			file:   "totp.png",
+			rotate: true,
+			want:   "otpauth://totp/Example:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Example",
+		},
+		{
+			file: "totp-low.png",
+			want: "otpauth://totp/x?secret=ABCDEFGH",
+		},
+		{
+			file: "hotp-high.png",
+			want: "otpauth://hotp/ACME%20Co:john.doe@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=ACME%20Co&algorithm=SHA256&digits=8&counter=42",
+		},
+		{
+			file: "migration.png",
+			want: migrationURI,
+		},
+	}
+
+	for _, tc := range testCases {
+		img := readImage(t, tc.file)
+		if tc.rotate {
+			img = rotate(img)
+		}
+		data, err := Decode(img)
+		if err != nil {
+			t.Errorf("%s (rotate=%t): could not decode: %v", tc.file, tc.rotate, err)
+			continue
+		}
+		if string(data) != tc.want {
+			t.Errorf("%s (rotate=%t): got %q, want %q", tc.file, tc.rotate, data, tc.want)
+		}
+	}
+}
+
+func TestDecodeWithoutCode(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	if _, err := Decode(img); err == nil {
+		t.Errorf("want an error for an image without a qr code")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package qr
+
+import (
+	"os"
+
+	"golang.org/x/xerrors"
+)
+
+// gfExp and gfLog are the exponent and logarithm tables for the GF(256) field
+// with the 0x11d primitive polynomial used by the QR codes.
+var gfExp [512]byte
+var gfLog [256]int
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+255-gfLog[b]]
+}
+
+func gfInverse(a byte) byte {
+	return gfExp[255-gfLog[a]]
+}
+
+// polyEval evaluates a polynomial with the coefficients in the increasing
+// order of powers.
+func polyEval(p []byte, x byte) byte {
+	var y byte
+	for i := len(p) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ p[i]
+	}
+	return y
+}
+
+// correctErrors fixes the errors in a block of data and error correction
+// codewords in place. Block can have up to nec/2 errors, where nec is the
+// number of error correction codewords at the end of the block.
+func correctErrors(block []byte, nec int) error {
+	n := len(block)
+
+	// Syndromes are the values of the block polynomial, where the first
+	// codeword is the coefficient of the highest power, at the roots of the
+	// generator polynomial.
+	syndromes := make([]byte, nec)
+	hasErrors := false
+	for i := 0; i < nec; i++ {
+		var s byte
+		for _, c := range block {
+			s = gfMul(s, gfExp[i]) ^ c
+		}
+		syndromes[i] = s
+		hasErrors = hasErrors || s != 0
+	}
+	if !hasErrors {
+		return nil
+	}
+
+	// Find the error locator polynomial with the Berlekamp-Massey algorithm.
+	locator, prev := []byte{1}, []byte{1}
+	nerrors, shift, prevDelta := 0, 1, byte(1)
+	for i := 0; i < nec; i++ {
+		delta := syndromes[i]
+		for j := 1; j <= nerrors && j < len(locator); j++ {
+			delta ^= gfMul(locator[j], syndromes[i-j])
+		}
+		if delta == 0 {
+			shift++
+			continue
+		}
+		next := make([]byte, len(locator))
+		copy(next, locator)
+		scale := gfDiv(delta, prevDelta)
+		for j, c := range prev {
+			for len(next) <= j+shift {
+				next = append(next, 0)
+			}
+			next[j+shift] ^= gfMul(scale, c)
+		}
+		if 2*nerrors <= i {
+			nerrors, prev, prevDelta, shift = i+1-nerrors, locator, delta, 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if 2*nerrors > nec {
+		return xerrors.Errorf("too many errors in the codewords: %w", os.ErrInvalid)
+	}
+
+	// Error evaluator polynomial is the product of syndromes and the error
+	// locator polynomials modulo x^nec.
+	evaluator := make([]byte, nec)
+	for i := 0; i < nec; i++ {
+		for j := 0; j <= i && j < len(locator); j++ {
+			evaluator[i] ^= gfMul(locator[j], syndromes[i-j])
+		}
+	}
+
+	// Formal derivative of the locator polynomial has only the odd powers in a
+	// field with characteristic two.
+	derivative := make([]byte, len(locator))
+	for i := 1; i < len(locator); i += 2 {
+		derivative[i-1] = locator[i]
+	}
+
+	// Find the error positions with the Chien search and the error values
+	// with the Forney algorithm.
+	nfound := 0
+	for k := 0; k < n; k++ {
+		power := n - 1 - k
+		xinv := gfExp[(255-power%255)%255]
+		if polyEval(locator, xinv) != 0 {
+			continue
+		}
+		denominator := polyEval(derivative, xinv)
+		if denominator == 0 {
+			return xerrors.Errorf("could not compute the error value: %w", os.ErrInvalid)
+		}
+		value := gfMul(gfInverse(xinv), gfDiv(polyEval(evaluator, xinv), denominator))
+		block[k] ^= value
+		nfound++
+	}
+	if nfound != nerrors {
+		return xerrors.Errorf("could not locate all errors in the codewords: %w", os.ErrInvalid)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [flags] <password-file> --at <revision>",
+	Short: "Restores a password-file from an older revision as a new commit.",
+	RunE:  cmdRestore,
+}
+
+func init() {
+	flags := restoreCmd.Flags()
+	flags.String("at", "", "Revision or date to restore the password-file from.")
+}
+
+func cmdRestore(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+
+	if len(args) != 1 {
+		return xerrors.Errorf("password file argument is required: %w", os.ErrInvalid)
+	}
+	file := args[0]
+	at, err := flags.GetString("at")
+	if err != nil {
+		return xerrors.Errorf("could not get --at value: %w", err)
+	}
+	if len(at) == 0 {
+		return xerrors.Errorf("--at value is required: %w", os.ErrInvalid)
+	}
+
+	commit, err := ps.ResolveRevision(at)
+	if err != nil {
+		return xerrors.Errorf("could not resolve revision %q: %w", at, err)
+	}
+	if err := ps.RestoreFile(file, commit); err != nil {
+		return xerrors.Errorf("could not restore %q from %q: %w", file, at, err)
+	}
+	fmt.Printf("Restored %q from commit %s.\n", file, commit[:12])
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm [flags] <password-file|directory>",
+	Short: "Removes a password-file or a directory of password-files.",
+	RunE:  cmdRm,
+}
+
+func init() {
+	flags := rmCmd.Flags()
+	flags.BoolP("recursive", "r", false, "When true, directories are removed recursively.")
+	flags.BoolP("force", "f", false, "When true, files are removed without a confirmation prompt.")
+}
+
+func cmdRm(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	ps, err := newPasswordStore(flags)
+	if err != nil {
+		return xerrors.Errorf("could not create password store instance: %w", err)
+	}
+
+	if len(args) == 0 {
+		return xerrors.Errorf("password file argument is required: %w", os.ErrInvalid)
+	}
+	if len(args) > 1 {
+		return xerrors.Errorf("too many arguments: %w", os.ErrInvalid)
+	}
+	path := filepath.Clean(filepath.Join("./", args[0]))
+	if path == "." {
+		return xerrors.Errorf("password store root directory cannot be removed: %w", os.ErrInvalid)
+	}
+
+	recursive, err := flags.GetBool("recursive")
+	if err != nil {
+		return xerrors.Errorf("could not get --recursive value: %w", err)
+	}
+	force, err := flags.GetBool("force")
+	if err != nil {
+		return xerrors.Errorf("could not get --force value: %w", err)
+	}
+
+	file := ps.EntryFile(path)
+	isFile, err := ps.FileExists(file)
+	if err != nil {
+		return xerrors.Errorf("could not check if file %q exists: %w", path, err)
+	}
+	isDir, err := ps.DirExists(path)
+	if err != nil {
+		return xerrors.Errorf("could not check if directory %q exists: %w", path, err)
+	}
+	if !isFile && !isDir {
+		return xerrors.Errorf("password file or directory %q doesn't exist: %w", path, os.ErrNotExist)
+	}
+	if isDir && !recursive {
+		if !isFile {
+			return xerrors.Errorf("%q is a directory (use --recursive): %w", path, os.ErrInvalid)
+		}
+		isDir = false
+	}
+
+	if !force {
+		prompt := fmt.Sprintf("Are you sure you would like to delete %q? [y/N] ", path)
+		if isDir {
+			prompt = fmt.Sprintf("Are you sure you would like to delete %q recursively? [y/N] ", path)
+		}
+		if yes, err := getConfirmation(prompt); err != nil {
+			return xerrors.Errorf("could not read confirmation: %w", err)
+		} else if !yes {
+			return nil
+		}
+	}
+
+	if isDir {
+		if err := ps.RemoveAll(path); err != nil {
+			return xerrors.Errorf("could not remove %q recursively: %w", path, err)
+		}
+		return nil
+	}
+	if err := ps.Remove(file); err != nil {
+		return xerrors.Errorf("could not remove file %q: %w", path, err)
+	}
+	return nil
+}
+
+func getConfirmation(prompt string) (bool, error) {
+	fmt.Print(prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, xerrors.Errorf("could not read user input: %w", err)
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bvk/past/git"
+	"golang.org/x/xerrors"
+)
+
+// Log returns the commits that modified a password file, newest first.
+// Commits for the password file in both gpg and age formats are included.
+func (ps *PasswordStore) Log(path string) ([]*git.LogItem, error) {
+	name := filepath.Clean(filepath.Join("./", path))
+	items, err := ps.store.Log(name+".gpg", name+".age")
+	if err != nil {
+		return nil, xerrors.Errorf("could not get log for %q: %w", path, err)
+	}
+	return items, nil
+}
+
+// ReadFileAt returns a password file's content at a revision in unencrypted
+// form. It returns os.ErrNotExist if the password file doesn't exist at the
+// revision.
+func (ps *PasswordStore) ReadFileAt(rev, path string) ([]byte, error) {
+	name := filepath.Clean(filepath.Join("./", path))
+	for _, file := range []string{name + ".gpg", name + ".age"} {
+		encrypted, err := ps.store.ReadFileAt(rev, file)
+		if err != nil {
+			if xerrors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, xerrors.Errorf("could not read file %q at %q: %w", file, rev, err)
+		}
+		decrypted, err := ps.decrypt(file, encrypted)
+		if err != nil {
+			return nil, xerrors.Errorf("could not decrypt file %q at %q: %w", file, rev, err)
+		}
+		return decrypted, nil
+	}
+	return nil, xerrors.Errorf("password file %q doesn't exist at %q: %w", path, rev, os.ErrNotExist)
+}
+
+// RestoreFile restores a password file's content from a revision as a new
+// commit. Restored content is encrypted with the current keys for the password
+// file, which may be different from the keys at the revision.
+func (ps *PasswordStore) RestoreFile(path, rev string) error {
+	data, err := ps.ReadFileAt(rev, path)
+	if err != nil {
+		return err
+	}
+	file := ps.EntryFile(path)
+	oldfile := ""
+	if yes, _ := ps.FileExists(file); yes {
+		oldfile = file
+	}
+	if err := ps.checkPolicy(file, oldfile, data); err != nil {
+		return err
+	}
+	keys, err := ps.FileKeys(file)
+	if err != nil {
+		return xerrors.Errorf("could not find appropriate keys for file %q: %w", file, err)
+	}
+	encrypted, err := ps.encrypt(file, data, keys)
+	if err != nil {
+		return xerrors.Errorf("could not encrypt password file data: %w", err)
+	}
+
+	msg := fmt.Sprintf("Restored password file %q from %s.", file, rev)
+	cb := func() error {
+		return ps.store.WriteFile(file, encrypted, os.FileMode(0644))
+	}
+	if err := ps.store.Apply(msg, cb); err != nil {
+		return xerrors.Errorf("could not restore password file %q: %w", file, err)
+	}
+	return nil
+}
+
+// Change describes a change to the password or to a key-value pair between
+// two versions of a password file. Old or New value is nil when the field is
+// added or removed respectively.
+type Change struct {
+	// Key is empty for the password.
+	Key string
+
+	Old, New *string
+}
+
+// Diff returns the changes between two versions of a password file's
+// decrypted content. Either of the versions can be nil when the password file
+// doesn't exist.
+func Diff(old, new []byte) []*Change {
+	var changes []*Change
+	if bytes.Equal(old, new) {
+		return nil
+	}
+
+	var opass, npass *string
+	ovs, nvs := NewValues(nil), NewValues(nil)
+	if old != nil {
+		password, data := Parse(old)
+		opass, ovs = &password, NewValues(data)
+	}
+	if new != nil {
+		password, data := Parse(new)
+		npass, nvs = &password, NewValues(data)
+	}
+	if !equal(opass, npass) {
+		changes = append(changes, &Change{Old: opass, New: npass})
+	}
+
+	for _, k := range unionKeys(ovs, nvs) {
+		if o, n := lookup(ovs, k), lookup(nvs, k); !equal(o, n) {
+			changes = append(changes, &Change{Key: k, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+// ResolveRevision returns the commit for a revision or a date. See
+// git.Dir.ResolveRevision for the accepted formats.
+func (ps *PasswordStore) ResolveRevision(rev string) (string, error) {
+	return ps.store.ResolveRevision(rev)
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package store
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Merge performs a three-way merge of the decrypted password file contents.
+// Password line and each key-value pair in the user data are merged
+// independently, so changes to different fields on both sides are combined. It
+// returns the merged content and the names of the fields that are changed
+// differently on both sides, in which case merged content is not valid.
+//
+// User data that is changed on only one side is taken as is, so free-form
+// user data that is not in key-value format is preserved.
+func Merge(base, ours, theirs []byte) ([]byte, []string) {
+	bpass, bdata := Parse(base)
+	opass, odata := Parse(ours)
+	tpass, tdata := Parse(theirs)
+
+	var conflicts []string
+	password, ok := merge3(&bpass, &opass, &tpass)
+	if !ok {
+		conflicts = append(conflicts, "password")
+	}
+
+	var data []byte
+	switch {
+	case bytes.Equal(odata, tdata):
+		data = odata
+	case bytes.Equal(bdata, odata):
+		data = tdata
+	case bytes.Equal(bdata, tdata):
+		data = odata
+	default:
+		bvs, ovs, tvs := NewValues(bdata), NewValues(odata), NewValues(tdata)
+		mvs := NewValues(nil)
+		for _, k := range unionKeys(bvs, ovs, tvs) {
+			// Missing keys are represented with a nil value, so that deleting a key
+			// on one side is merged like any other change.
+			v, ok := merge3(lookup(bvs, k), lookup(ovs, k), lookup(tvs, k))
+			if !ok {
+				conflicts = append(conflicts, k)
+				continue
+			}
+			if v != nil {
+				mvs.Set(k, *v)
+			}
+		}
+		data = mvs.Bytes()
+	}
+
+	if len(conflicts) > 0 {
+		return nil, conflicts
+	}
+	return Format(*password, data), nil
+}
+
+// unionKeys returns the keys from all input key-value sets in sorted order.
+func unionKeys(vss ...*Values) []string {
+	keyMap := make(map[string]struct{})
+	for _, vs := range vss {
+		for k := range vs.m {
+			keyMap[k] = struct{}{}
+		}
+	}
+	var keys []string
+	for k := range keyMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func lookup(vs *Values, key string) *string {
+	if v, ok := vs.m[key]; ok {
+		return &v
+	}
+	return nil
+}
+
+// merge3 returns the merged value for a field and false if the field is
+// changed differently on both sides. Nil values represent a missing field.
+func merge3(base, ours, theirs *string) (*string, bool) {
+	switch {
+	case equal(ours, theirs):
+		return ours, true
+	case equal(base, ours):
+		return theirs, true
+	case equal(base, theirs):
+		return ours, true
+	}
+	return nil, false
+}
+
+func equal(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -61,23 +61,63 @@ func ToPublicKeyData(pk *gpg.PublicKey, sk *gpg.SecretKey) *PublicKeyData {
 	return pkd
 }
 
+// Crypter is the interface for encryption backends used by the password store.
+// Keys are identified by their fingerprints or key ids.
+type Crypter interface {
+	// Encrypt encrypts the input data for all the recipient keys.
+	Encrypt(data []byte, fps []string) ([]byte, error)
+
+	// Decrypt decrypts the input data with one of the available secret keys.
+	Decrypt(data []byte) ([]byte, error)
+
+	// Recipients returns the key ids that can decrypt the encrypted input data.
+	Recipients(data []byte) ([]string, error)
+
+	// PublicKeys returns all keys that can be used as recipients.
+	PublicKeys() []*gpg.PublicKey
+
+	// SecretKeys returns all keys that can be used for decryption.
+	SecretKeys() []*gpg.SecretKey
+}
+
+var _ Crypter = &gpg.Keyring{}
+
 type PasswordStore struct {
 	store   *git.Dir
-	keyring *gpg.Keyring
+	crypter Crypter
+
+	// ageCrypter is used for the age encrypted password files, if not nil.
+	ageCrypter Crypter
 
 	gitFiles []string
 
 	dirKeysMap map[string][]string
 
+	ageDirKeysMap map[string][]string
+
 	gpgKeyMap map[string]*PublicKeyData
+
+	// keys when not empty are used for all gpg encrypted password-files in
+	// place of the keys from .gpg-id files.
+	keys []string
+
+	// signingKeys when not empty must sign the .gpg-id files. They are
+	// resolved to the fingerprints in signingFingerprints. Directories with
+	// verified .gpg-id files are cached in verifiedDirs.
+	signingKeys         []string
+	signingFingerprints map[string]bool
+	verifiedDirs        map[string]bool
 }
 
-func Create(store *git.Dir, keyring *gpg.Keyring, fingerprints []string) (_ *PasswordStore, status error) {
+// Create initializes a password store in an empty git repository. When signing
+// keys are given, the .gpg-id file is signed with one of them and they are
+// required to sign the .gpg-id files in the password store.
+func Create(store *git.Dir, crypter Crypter, fingerprints, signingKeys []string) (_ *PasswordStore, status error) {
 	if store == nil {
 		return nil, xerrors.Errorf("git repository cannot be nil: %w", os.ErrInvalid)
 	}
-	if keyring == nil {
-		return nil, xerrors.Errorf("keyring cannot be nil: %w", os.ErrInvalid)
+	if crypter == nil {
+		return nil, xerrors.Errorf("crypter cannot be nil: %w", os.ErrInvalid)
 	}
 
 	file := "./.gpg-id"
@@ -94,20 +134,45 @@ func Create(store *git.Dir, keyring *gpg.Keyring, fingerprints []string) (_ *Pas
 		}
 	}()
 
+	if len(signingKeys) > 0 {
+		signer, ok := crypter.(Signer)
+		if !ok {
+			return nil, xerrors.Errorf("encryption backend cannot create signatures: %w", os.ErrInvalid)
+		}
+		signature, err := SignFile(signer, []byte(content), signingKeys)
+		if err != nil {
+			return nil, xerrors.Errorf("could not sign file %q: %w", file, err)
+		}
+		if err := store.CreateFile(file+".sig", signature, os.FileMode(0644)); err != nil {
+			return nil, xerrors.Errorf("could not add file %q in git repo: %w", file+".sig", err)
+		}
+	}
+
 	msg := fmt.Sprintf("Initialized password store with keys %q", fingerprints)
 	if err := store.Commit(msg); err != nil {
 		return nil, xerrors.Errorf("could not commit gpg keys file: %w", err)
 	}
 
-	return New(store, keyring)
+	ps, err := New(store, crypter, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.SetSigningKeys(signingKeys); err != nil {
+		return nil, err
+	}
+	return ps, nil
 }
 
-func New(store *git.Dir, keyring *gpg.Keyring) (*PasswordStore, error) {
+// New creates a password store instance for an existing git repository. Input
+// crypter is used for the .gpg password files and ageCrypter is used for the
+// .age password files. One of them can be nil if the store doesn't use that
+// format.
+func New(store *git.Dir, crypter, ageCrypter Crypter) (*PasswordStore, error) {
 	if store == nil {
 		return nil, xerrors.Errorf("git repository cannot be nil: %w", os.ErrInvalid)
 	}
-	if keyring == nil {
-		return nil, xerrors.Errorf("keyring cannot be nil: %w", os.ErrInvalid)
+	if crypter == nil && ageCrypter == nil {
+		return nil, xerrors.Errorf("crypter cannot be nil: %w", os.ErrInvalid)
 	}
 
 	gitFiles, err := store.ListFiles()
@@ -118,64 +183,192 @@ func New(store *git.Dir, keyring *gpg.Keyring) (*PasswordStore, error) {
 		gitFiles[i] = filepath.Clean(filepath.Join("./", file))
 	}
 
-	// Scan all git files to identify .gpg-id files for each directory.
+	// Scan all git files to identify .gpg-id and .age-recipients files for each
+	// directory.
 	dirKeysMap := make(map[string][]string)
+	ageDirKeysMap := make(map[string][]string)
 	for _, path := range gitFiles {
 		base := filepath.Base(path)
-		if base == ".gpg-id" {
+		if base == ".gpg-id" || base == ".age-recipients" {
 			data, err := store.ReadFile(path)
 			if err != nil {
 				return nil, xerrors.Errorf("could not read file %q: %w", path, err)
 			}
-			dirKeysMap[filepath.Dir(path)] = strings.Fields(string(data))
+			if base == ".gpg-id" {
+				dirKeysMap[filepath.Dir(path)] = strings.Fields(string(data))
+			} else {
+				ageDirKeysMap[filepath.Dir(path)] = parseAgeRecipients(data)
+			}
 		}
 	}
-	if _, ok := dirKeysMap["."]; !ok {
-		return nil, xerrors.Errorf("could not find .gpg-id file in the git root directory: %w", os.ErrInvalid)
-	}
-
-	skeyMap := make(map[string]*gpg.SecretKey)
-	for _, skey := range keyring.SecretKeys() {
-		skeyMap[skey.Fingerprint] = skey
-		skeyMap[skey.KeyID] = skey
+	_, hasGPG := dirKeysMap["."]
+	_, hasAge := ageDirKeysMap["."]
+	if !hasGPG && !hasAge {
+		return nil, xerrors.Errorf("could not find .gpg-id or .age-recipients file in the git root directory: %w", os.ErrInvalid)
 	}
 
 	gpgKeyMap := make(map[string]*PublicKeyData)
-	for _, pkey := range keyring.PublicKeys() {
-		if skey, ok := skeyMap[pkey.Fingerprint]; ok {
-			v := ToPublicKeyData(pkey, skey)
-			gpgKeyMap[pkey.Fingerprint] = v
-			gpgKeyMap[pkey.KeyID] = v
-		} else {
-			v := ToPublicKeyData(pkey, nil)
-			gpgKeyMap[pkey.Fingerprint] = v
-			gpgKeyMap[pkey.KeyID] = v
+	for _, c := range []Crypter{crypter, ageCrypter} {
+		if c == nil {
+			continue
+		}
+		skeyMap := make(map[string]*gpg.SecretKey)
+		for _, skey := range c.SecretKeys() {
+			skeyMap[skey.Fingerprint] = skey
+			skeyMap[skey.KeyID] = skey
+		}
+		for _, pkey := range c.PublicKeys() {
+			if skey, ok := skeyMap[pkey.Fingerprint]; ok {
+				v := ToPublicKeyData(pkey, skey)
+				gpgKeyMap[pkey.Fingerprint] = v
+				gpgKeyMap[pkey.KeyID] = v
+			} else {
+				v := ToPublicKeyData(pkey, nil)
+				gpgKeyMap[pkey.Fingerprint] = v
+				gpgKeyMap[pkey.KeyID] = v
+			}
 		}
 	}
 
 	ps := &PasswordStore{
-		store:      store,
-		keyring:    keyring,
-		gitFiles:   gitFiles,
-		gpgKeyMap:  gpgKeyMap,
-		dirKeysMap: dirKeysMap,
+		store:         store,
+		crypter:       crypter,
+		ageCrypter:    ageCrypter,
+		gitFiles:      gitFiles,
+		gpgKeyMap:     gpgKeyMap,
+		dirKeysMap:    dirKeysMap,
+		ageDirKeysMap: ageDirKeysMap,
 	}
 	return ps, nil
 }
 
+// parseAgeRecipients returns the recipients from an .age-recipients file
+// content. Empty lines and lines beginning with '#' are ignored.
+func parseAgeRecipients(data []byte) []string {
+	var recipients []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		recipients = append(recipients, line)
+	}
+	return recipients
+}
+
+// EntryFile returns the git file path for a password file name. Existing
+// password files are found with either .gpg or .age extension. Otherwise, the
+// extension is chosen by the nearest directory with a .gpg-id or
+// .age-recipients file, preferring age when a directory has both.
+func (ps *PasswordStore) EntryFile(path string) string {
+	name := filepath.Clean(filepath.Join("./", path))
+	for _, ext := range []string{".gpg", ".age"} {
+		if yes, _ := ps.FileExists(name + ext); yes {
+			return name + ext
+		}
+	}
+	return name + entryExt(ps.dirKeysMap, ps.ageDirKeysMap, name)
+}
+
+// entryExt returns the password file extension for a new password file based
+// on the nearest directory with a .gpg-id or .age-recipients file.
+func entryExt(dirKeysMap, ageDirKeysMap map[string][]string, path string) string {
+	for d := filepath.Dir(path); ; d = filepath.Dir(d) {
+		if _, ok := ageDirKeysMap[d]; ok {
+			return ".age"
+		}
+		if _, ok := dirKeysMap[d]; ok {
+			return ".gpg"
+		}
+		if d == "." {
+			break
+		}
+	}
+	return ".gpg"
+}
+
+// isEntryFile returns true if input git file path is a password file.
+func isEntryFile(file string) bool {
+	return strings.HasSuffix(file, ".gpg") || strings.HasSuffix(file, ".age")
+}
+
+// crypterFor returns the encryption backend for a password file.
+func (ps *PasswordStore) crypterFor(file string) (Crypter, error) {
+	c := ps.crypter
+	if strings.HasSuffix(file, ".age") {
+		c = ps.ageCrypter
+	}
+	if c == nil {
+		return nil, xerrors.Errorf("no encryption backend is configured for %q: %w", file, os.ErrInvalid)
+	}
+	return c, nil
+}
+
+func (ps *PasswordStore) encrypt(file string, data []byte, keys []string) ([]byte, error) {
+	c, err := ps.crypterFor(file)
+	if err != nil {
+		return nil, err
+	}
+	return c.Encrypt(data, keys)
+}
+
+func (ps *PasswordStore) decrypt(file string, data []byte) ([]byte, error) {
+	c, err := ps.crypterFor(file)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decrypt(data)
+}
+
+// Decrypt decrypts the encrypted content for a password file. Input content
+// need not be the current content of the file, so it can be used with other
+// versions of the file like the ones in a merge.
+func (ps *PasswordStore) Decrypt(file string, encrypted []byte) ([]byte, error) {
+	decrypted, err := ps.decrypt(file, encrypted)
+	if err != nil {
+		return nil, xerrors.Errorf("could not decrypt data for file %q: %w", file, err)
+	}
+	return decrypted, nil
+}
+
+// Encrypt encrypts the input content with the keys for a password file. Input
+// content is not written to the file.
+func (ps *PasswordStore) Encrypt(file string, decrypted []byte) ([]byte, error) {
+	keys, err := ps.FileKeys(file)
+	if err != nil {
+		return nil, xerrors.Errorf("could not find appropriate keys for file %q: %w", file, err)
+	}
+	if len(keys) == 0 {
+		return nil, xerrors.Errorf("could not find any keys for file %q: %w", file, os.ErrNotExist)
+	}
+	encrypted, err := ps.encrypt(file, decrypted, keys)
+	if err != nil {
+		return nil, xerrors.Errorf("could not encrypt data for file %q: %w", file, err)
+	}
+	return encrypted, nil
+}
+
 func (ps *PasswordStore) ListFiles() ([]string, error) {
 	var files []string
 	for _, file := range ps.gitFiles {
-		if strings.HasSuffix(file, ".gpg") {
-			files = append(files, strings.TrimSuffix(file, ".gpg"))
+		if isEntryFile(file) {
+			files = append(files, strings.TrimSuffix(file, filepath.Ext(file)))
 		}
 	}
 	return files, nil
 }
 
 func (ps *PasswordStore) Recipients(path string) ([]string, error) {
-	file := filepath.Clean(filepath.Join(ps.store.RootDir(), path+".gpg"))
-	ids, err := gpg.GetRecipients(file)
+	file := ps.EntryFile(path)
+	encrypted, err := ps.store.ReadFile(file)
+	if err != nil {
+		return nil, xerrors.Errorf("could not read file %q: %w", file, err)
+	}
+	c, err := ps.crypterFor(file)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := c.Recipients(encrypted)
 	if err != nil {
 		return nil, xerrors.Errorf("could not determine recipients for %q: %w", path, err)
 	}
@@ -191,12 +384,12 @@ func (ps *PasswordStore) RecipientPublicKey(id string) (*PublicKeyData, error) {
 
 // ReadFile returns a password file's content in unencrypted form.
 func (ps *PasswordStore) ReadFile(path string) ([]byte, error) {
-	file := filepath.Clean(filepath.Join("./", path+".gpg"))
+	file := ps.EntryFile(path)
 	encrypted, err := ps.store.ReadFile(file)
 	if err != nil {
 		return nil, xerrors.Errorf("could not read file %q: %w", file, err)
 	}
-	decrypted, err := ps.keyring.Decrypt(encrypted)
+	decrypted, err := ps.decrypt(file, encrypted)
 	if err != nil {
 		return nil, xerrors.Errorf("could not decrypt file %q: %w", file, err)
 	}
@@ -208,12 +401,20 @@ func (ps *PasswordStore) ReadFile(path string) ([]byte, error) {
 // user data prepared using the Format function. New password file will be
 // created with the input mode if target file doesn't exist.
 func (ps *PasswordStore) WriteFile(path string, data []byte, mode os.FileMode) error {
-	file := filepath.Clean(filepath.Join("./", path+".gpg"))
+	file := ps.EntryFile(path)
+	oldfile := ""
+	if yes, _ := ps.FileExists(file); yes {
+		oldfile = file
+	}
+	data, err := ps.prepareFile(file, oldfile, data, false)
+	if err != nil {
+		return err
+	}
 	keys, err := ps.FileKeys(file)
 	if err != nil {
 		return xerrors.Errorf("could not find appropriate keys for file %q: %w", file, err)
 	}
-	encrypted, err := ps.keyring.Encrypt(data, keys)
+	encrypted, err := ps.encrypt(file, data, keys)
 	if err != nil {
 		return xerrors.Errorf("could not encrypt password file data: %w", err)
 	}
@@ -233,17 +434,38 @@ func (ps *PasswordStore) WriteFile(path string, data []byte, mode os.FileMode) e
 
 // UpdateFile is similar to WriteFile, but fails if target file doesn't exist.
 func (ps *PasswordStore) UpdateFile(path string, data []byte) error {
-	file := filepath.Clean(filepath.Join("./", path+".gpg"))
+	return ps.updateFile(path, data, "Updated password file %q.", false)
+}
+
+// UpdateCounter is similar to UpdateFile, but is meant for the counter updates,
+// like the HOTP counters, which must not be blocked by the password age limits
+// of the policies. Password in the input data must be unchanged.
+func (ps *PasswordStore) UpdateCounter(path string, data []byte) error {
+	return ps.updateFile(path, data, "Updated counter in password file %q.", true)
+}
+
+// RotateFile is similar to UpdateFile, but records the change as a password
+// rotation in the commit message.
+func (ps *PasswordStore) RotateFile(path string, data []byte) error {
+	return ps.updateFile(path, data, "Rotated password in file %q.", false)
+}
+
+func (ps *PasswordStore) updateFile(path string, data []byte, format string, counter bool) error {
+	file := ps.EntryFile(path)
+	data, err := ps.prepareFile(file, file, data, counter)
+	if err != nil {
+		return err
+	}
 	keys, err := ps.FileKeys(file)
 	if err != nil {
 		return xerrors.Errorf("could not find appropriate keys for file %q: %w", file, err)
 	}
-	encrypted, err := ps.keyring.Encrypt(data, keys)
+	encrypted, err := ps.encrypt(file, data, keys)
 	if err != nil {
 		return xerrors.Errorf("could not encrypt password file data: %w", err)
 	}
 
-	msg := fmt.Sprintf("Updated password file %q.", file)
+	msg := fmt.Sprintf(format, file)
 	cb := func() error {
 		return ps.store.UpdateFile(file, encrypted)
 	}
@@ -255,12 +477,16 @@ func (ps *PasswordStore) UpdateFile(path string, data []byte) error {
 
 // CreateFile is similar to WriteFile, but fails if target file already exists.
 func (ps *PasswordStore) CreateFile(path string, data []byte, mode os.FileMode) error {
-	file := filepath.Clean(filepath.Join("./", path+".gpg"))
+	file := ps.EntryFile(path)
+	data, err := ps.prepareFile(file, "", data, false)
+	if err != nil {
+		return err
+	}
 	keys, err := ps.FileKeys(file)
 	if err != nil {
 		return xerrors.Errorf("could not find appropriate keys for file %q: %w", file, err)
 	}
-	encrypted, err := ps.keyring.Encrypt(data, keys)
+	encrypted, err := ps.encrypt(file, data, keys)
 	if err != nil {
 		return xerrors.Errorf("could not encrypt password file data: %w", err)
 	}
@@ -276,13 +502,17 @@ func (ps *PasswordStore) CreateFile(path string, data []byte, mode os.FileMode)
 }
 
 func (ps *PasswordStore) ReplaceFile(oldpath, newpath string, data []byte) error {
-	oldfile := filepath.Clean(filepath.Join("./", oldpath+".gpg"))
-	newfile := filepath.Clean(filepath.Join("./", newpath+".gpg"))
+	oldfile := ps.EntryFile(oldpath)
+	newfile := ps.EntryFile(newpath)
+	data, err := ps.prepareFile(newfile, oldfile, data, false)
+	if err != nil {
+		return err
+	}
 	keys, err := ps.FileKeys(newfile)
 	if err != nil {
 		return xerrors.Errorf("could not find appropriate keys for file %q: %w", newfile, err)
 	}
-	encrypted, err := ps.keyring.Encrypt(data, keys)
+	encrypted, err := ps.encrypt(newfile, data, keys)
 	if err != nil {
 		return xerrors.Errorf("could not encrypt password file data: %w", err)
 	}
@@ -311,8 +541,37 @@ func (ps *PasswordStore) Remove(file string) error {
 	return nil
 }
 
-func (ps *PasswordStore) RemoveAll(file string) error {
-	return xerrors.New("TODO")
+// RemoveAll removes a password file and all password files in the directory
+// with the same name, including any nested .gpg-id files, in a single commit.
+func (ps *PasswordStore) RemoveAll(path string) error {
+	dir := filepath.Clean(filepath.Join("./", path))
+	if dir == "." {
+		return xerrors.Errorf("cannot remove the password store root directory: %w", os.ErrInvalid)
+	}
+
+	var files []string
+	for _, file := range ps.gitFiles {
+		if file == dir+".gpg" || file == dir+".age" || strings.HasPrefix(file, dir+string(filepath.Separator)) {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return xerrors.Errorf("could not find password file or directory %q: %w", path, os.ErrNotExist)
+	}
+
+	msg := fmt.Sprintf("Removed %q recursively.", dir)
+	cb := func() error {
+		for _, file := range files {
+			if err := ps.store.RemoveFile(file); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := ps.store.Apply(msg, cb); err != nil {
+		return xerrors.Errorf("could not remove %q recursively: %w", dir, err)
+	}
+	return nil
 }
 
 func (ps *PasswordStore) Rename(oldpath, newpath string) error {
@@ -326,6 +585,229 @@ func (ps *PasswordStore) Rename(oldpath, newpath string) error {
 	return nil
 }
 
+// Move renames a password file or a directory of password files to a new
+// location in a single commit. Password files are re-encrypted when the
+// recipients at the destination are different from the recipients at the
+// source. When destination is an existing directory, source is moved into that
+// directory.
+func (ps *PasswordStore) Move(src, dst string, overwrite bool) error {
+	pairs, err := ps.transferPairs(src, dst)
+	if err != nil {
+		return xerrors.Errorf("could not determine files to move: %w", err)
+	}
+	msg := fmt.Sprintf("Moved %q to %q.", src, dst)
+	if err := ps.transfer(msg, pairs, true /* remove */, overwrite); err != nil {
+		return xerrors.Errorf("could not move %q to %q: %w", src, dst, err)
+	}
+	return nil
+}
+
+// Copy is similar to Move, but source password files are not removed.
+func (ps *PasswordStore) Copy(src, dst string, overwrite bool) error {
+	pairs, err := ps.transferPairs(src, dst)
+	if err != nil {
+		return xerrors.Errorf("could not determine files to copy: %w", err)
+	}
+	msg := fmt.Sprintf("Copied %q to %q.", src, dst)
+	if err := ps.transfer(msg, pairs, false /* remove */, overwrite); err != nil {
+		return xerrors.Errorf("could not copy %q to %q: %w", src, dst, err)
+	}
+	return nil
+}
+
+// transferPairs returns the source and destination git file paths for moving
+// or copying a password file or a directory.
+func (ps *PasswordStore) transferPairs(src, dst string) ([][2]string, error) {
+	intoDir := strings.HasSuffix(dst, "/")
+	src = filepath.Clean(filepath.Join("./", src))
+	dst = filepath.Clean(filepath.Join("./", dst))
+	if src == "." {
+		return nil, xerrors.Errorf("password store root directory cannot be the source: %w", os.ErrInvalid)
+	}
+	if yes, _ := ps.DirExists(dst); yes || intoDir {
+		dst = filepath.Join(dst, filepath.Base(src))
+	}
+	if src == dst {
+		return nil, xerrors.Errorf("source and destination are the same: %w", os.ErrInvalid)
+	}
+
+	if file := ps.EntryFile(src); isEntryFile(file) {
+		if yes, _ := ps.FileExists(file); yes {
+			return [][2]string{{file, dst + filepath.Ext(file)}}, nil
+		}
+	}
+
+	if strings.HasPrefix(dst, src+string(filepath.Separator)) {
+		return nil, xerrors.Errorf("directory %q cannot be moved into itself: %w", src, os.ErrInvalid)
+	}
+	var pairs [][2]string
+	for _, file := range ps.gitFiles {
+		if strings.HasPrefix(file, src+string(filepath.Separator)) {
+			rel := strings.TrimPrefix(file, src+string(filepath.Separator))
+			pairs = append(pairs, [2]string{file, filepath.Join(dst, rel)})
+		}
+	}
+	if len(pairs) == 0 {
+		return nil, xerrors.Errorf("could not find password file or directory %q: %w", src, os.ErrNotExist)
+	}
+	return pairs, nil
+}
+
+// transfer copies files in the source and destination pairs in a single
+// commit. Password files are re-encrypted if their recipients change at the
+// destination, including the changes from any .gpg-id files in the pairs.
+func (ps *PasswordStore) transfer(msg string, pairs [][2]string, remove, overwrite bool) error {
+	// Prepare .gpg-id and .age-recipients files mapping after the transfer.
+	newKeysMap := func(idFile string, dirKeysMap map[string][]string) map[string][]string {
+		newMap := make(map[string][]string)
+		for dir, keys := range dirKeysMap {
+			newMap[dir] = keys
+		}
+		for _, pair := range pairs {
+			if remove && filepath.Base(pair[0]) == idFile {
+				delete(newMap, filepath.Dir(pair[0]))
+			}
+		}
+		for _, pair := range pairs {
+			if filepath.Base(pair[0]) == idFile {
+				newMap[filepath.Dir(pair[1])] = dirKeysMap[filepath.Dir(pair[0])]
+			}
+		}
+		return newMap
+	}
+	newDirKeysMap := newKeysMap(".gpg-id", ps.dirKeysMap)
+	newAgeDirKeysMap := newKeysMap(".age-recipients", ps.ageDirKeysMap)
+
+	// Keys for the gpg password files at the destination are verified like in
+	// FileKeys. The .gpg-id files that arrive with the transfer are verified
+	// in their source directories, where their signature files are.
+	movedKeysDirs := make(map[string]string)
+	movedPolicyDirs := make(map[string]string)
+	for _, pair := range pairs {
+		switch filepath.Base(pair[0]) {
+		case ".gpg-id":
+			movedKeysDirs[filepath.Dir(pair[1])] = filepath.Dir(pair[0])
+		case PolicyFile:
+			movedPolicyDirs[filepath.Dir(pair[1])] = filepath.Dir(pair[0])
+		}
+	}
+	destKeys := func(to string) ([]string, error) {
+		if strings.HasSuffix(to, ".age") {
+			return dirKeys(newAgeDirKeysMap, to), nil
+		}
+		if len(ps.keys) > 0 {
+			return append([]string{}, ps.keys...), nil
+		}
+		dir := keysDir(newDirKeysMap, to)
+		if src, ok := movedKeysDirs[dir]; ok {
+			dir = src
+		}
+		if err := ps.verifyKeysFile(dir); err != nil {
+			return nil, err
+		}
+		return dirKeys(newDirKeysMap, to), nil
+	}
+
+	// Destination format is chosen by the recipients at the destination, so
+	// existing password files with either extension are overwritten.
+	var overwritten []string
+	newKeys := make([][]string, len(pairs))
+	for i, pair := range pairs {
+		if !isEntryFile(pair[1]) {
+			if yes, _ := ps.FileExists(pair[1]); yes && !overwrite {
+				return xerrors.Errorf("destination file %q already exists: %w", pair[1], os.ErrExist)
+			}
+			continue
+		}
+		name := strings.TrimSuffix(pair[1], filepath.Ext(pair[1]))
+		pairs[i][1] = name + entryExt(newDirKeysMap, newAgeDirKeysMap, pair[1])
+		keys, err := destKeys(pairs[i][1])
+		if err != nil {
+			return xerrors.Errorf("could not find appropriate keys for file %q: %w", pairs[i][1], err)
+		}
+		newKeys[i] = keys
+
+		// Password files must satisfy the policy at the destination, including
+		// the policy files that arrive with the transfer.
+		policy, err := ps.filePolicy(pairs[i][1], movedPolicyDirs)
+		if err != nil {
+			return err
+		}
+		if policy != nil {
+			encrypted, err := ps.store.ReadFile(pair[0])
+			if err != nil {
+				return xerrors.Errorf("could not read file %q: %w", pair[0], err)
+			}
+			decrypted, err := ps.decrypt(pair[0], encrypted)
+			if err != nil {
+				return xerrors.Errorf("could not decrypt file %q: %w", pair[0], err)
+			}
+			if err := ps.checkFilePolicy(policy, pairs[i][1], pair[0], decrypted, decrypted); err != nil {
+				return err
+			}
+		}
+		for _, ext := range []string{".gpg", ".age"} {
+			if yes, _ := ps.FileExists(name + ext); yes {
+				if !overwrite {
+					return xerrors.Errorf("destination file %q already exists: %w", name+ext, os.ErrExist)
+				}
+				if name+ext != pairs[i][1] {
+					overwritten = append(overwritten, name+ext)
+				}
+			}
+		}
+	}
+
+	cb := func() error {
+		for _, file := range overwritten {
+			if err := ps.store.RemoveFile(file); err != nil {
+				return xerrors.Errorf("could not remove file %q: %w", file, err)
+			}
+		}
+		for i, pair := range pairs {
+			from, to := pair[0], pair[1]
+			stat, err := ps.store.Stat(from)
+			if err != nil {
+				return xerrors.Errorf("could not stat file %q: %w", from, err)
+			}
+			data, err := ps.store.ReadFile(from)
+			if err != nil {
+				return xerrors.Errorf("could not read file %q: %w", from, err)
+			}
+			if isEntryFile(from) {
+				oldKeys := dirKeys(ps.dirKeysMap, from)
+				if strings.HasSuffix(from, ".age") {
+					oldKeys = dirKeys(ps.ageDirKeysMap, from)
+				}
+				if filepath.Ext(from) != filepath.Ext(to) || !sameKeys(oldKeys, newKeys[i]) {
+					decrypted, err := ps.decrypt(from, data)
+					if err != nil {
+						return xerrors.Errorf("could not decrypt file %q: %w", from, err)
+					}
+					encrypted, err := ps.encrypt(to, decrypted, newKeys[i])
+					if err != nil {
+						return xerrors.Errorf("could not reencrypt file %q: %w", from, err)
+					}
+					data = encrypted
+				}
+			}
+			if err := ps.store.WriteFile(to, data, stat.Mode()); err != nil {
+				return xerrors.Errorf("could not write file %q: %w", to, err)
+			}
+			if remove {
+				if err := ps.store.RemoveFile(from); err != nil {
+					return xerrors.Errorf("could not remove file %q: %w", from, err)
+				}
+			}
+		}
+		return nil
+	}
+	if err := ps.store.Apply(msg, cb); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (ps *PasswordStore) FileExists(path string) (bool, error) {
 	for _, file := range ps.gitFiles {
 		if file == path {
@@ -335,61 +817,204 @@ func (ps *PasswordStore) FileExists(path string) (bool, error) {
 	return false, nil
 }
 
+// DirExists returns true if the input path is a directory with at least one
+// file in the password store.
+func (ps *PasswordStore) DirExists(path string) (bool, error) {
+	dir := filepath.Clean(filepath.Join("./", path))
+	if dir == "." {
+		return true, nil
+	}
+	for _, file := range ps.gitFiles {
+		if strings.HasPrefix(file, dir+string(filepath.Separator)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FileKeys returns the recipient keys for a password file from the nearest
+// .gpg-id file, or from the nearest .age-recipients file for the .age files.
 func (ps *PasswordStore) FileKeys(path string) ([]string, error) {
-	keys := ps.dirKeysMap["."]
+	if strings.HasSuffix(path, ".age") {
+		return dirKeys(ps.ageDirKeysMap, path), nil
+	}
+	if len(ps.keys) > 0 {
+		return append([]string{}, ps.keys...), nil
+	}
+	if err := ps.verifyKeysFile(keysDir(ps.dirKeysMap, path)); err != nil {
+		return nil, err
+	}
+	return dirKeys(ps.dirKeysMap, path), nil
+}
+
+// SetKeys overrides the keys from the .gpg-id files for encrypting the gpg
+// password-files, like the PASSWORD_STORE_KEY environment variable in pass.
+// Empty input restores the keys from the .gpg-id files.
+func (ps *PasswordStore) SetKeys(keys []string) {
+	ps.keys = append([]string{}, keys...)
+}
+
+// dirKeys returns the keys from the nearest .gpg-id file for a path.
+func dirKeys(dirKeysMap map[string][]string, path string) []string {
+	return append([]string{}, dirKeysMap[keysDir(dirKeysMap, path)]...)
+}
+
+// keysDir returns the nearest directory with a .gpg-id file for a path.
+func keysDir(dirKeysMap map[string][]string, path string) string {
 	for d := filepath.Dir(path); d != "."; d = filepath.Dir(d) {
-		if ks, ok := ps.dirKeysMap[d]; ok {
-			keys = ks
-			break
+		if _, ok := dirKeysMap[d]; ok {
+			return d
 		}
 	}
-	return append([]string{}, keys...), nil
+	return "."
 }
 
+// nestedKeyDir returns the nearest directory with a .gpg-id file for a path
+// that is below the input directory. Returns empty string if no such directory
+// exists.
+func nestedKeyDir(dirKeysMap map[string][]string, dir, path string) string {
+	for d := filepath.Dir(path); d != dir && d != "."; d = filepath.Dir(d) {
+		if _, ok := dirKeysMap[d]; ok {
+			return d
+		}
+	}
+	return ""
+}
+
+// sameKeys returns true if both inputs have the same set of keys.
+func sameKeys(a, b []string) bool {
+	am := make(map[string]struct{})
+	for _, k := range a {
+		am[k] = struct{}{}
+	}
+	bm := make(map[string]struct{})
+	for _, k := range b {
+		if _, ok := am[k]; !ok {
+			return false
+		}
+		bm[k] = struct{}{}
+	}
+	return len(am) == len(bm)
+}
+
+// Reinit updates the .gpg-id file in a directory with new key fingerprints and
+// re-encrypts all password files governed by it, including the password files
+// in the sub-directories. Sub-directories with their own .gpg-id file are left
+// alone. Up to nskip password files that cannot be decrypted are skipped.
 func (ps *PasswordStore) Reinit(directory string, fingerprints []string, nskip int) error {
-	dirPath := filepath.Clean(filepath.Join(ps.store.RootDir(), directory))
+	return ps.reinit(directory, ".gpg-id", fingerprints, nskip)
+}
+
+// ReinitAge is similar to Reinit, but updates the .age-recipients file in a
+// directory with new age recipients. Password files with .gpg extension that
+// are governed by the directory are converted into .age files, which can be
+// used to migrate a directory from GPG to age.
+func (ps *PasswordStore) ReinitAge(directory string, recipients []string, nskip int) error {
+	return ps.reinit(directory, ".age-recipients", recipients, nskip)
+}
+
+func (ps *PasswordStore) reinit(directory, idFileName string, fingerprints []string, nskip int) error {
+	dir := filepath.Clean(filepath.Join("./", directory))
+	if len(fingerprints) == 0 {
+		return xerrors.Errorf("at least one key fingerprint is required: %w", os.ErrInvalid)
+	}
+
+	// GPG keys only apply to the .gpg files, but age recipients apply to both
+	// .gpg and .age files, so that directories can be migrated to age.
+	ext, keysMap := ".gpg", ps.dirKeysMap
+	if idFileName == ".age-recipients" {
+		ext, keysMap = ".age", make(map[string][]string)
+		for d, keys := range ps.dirKeysMap {
+			keysMap[d] = keys
+		}
+		for d, keys := range ps.ageDirKeysMap {
+			keysMap[d] = keys
+		}
+	}
+
+	var files []string
+	for _, file := range ps.gitFiles {
+		if !isEntryFile(file) || (ext == ".gpg" && !strings.HasSuffix(file, ext)) {
+			continue
+		}
+		if dir != "." && !strings.HasPrefix(file, dir+string(filepath.Separator)) {
+			continue
+		}
+		if keyDir := nestedKeyDir(keysMap, dir, file); keyDir != "" {
+			log.Printf("file %q is skipped cause it has keys from the directory %q", file, keyDir)
+			continue
+		}
+		files = append(files, file)
+	}
 
-	msg := fmt.Sprintf("Reinitialized the store with keys %q.", fingerprints)
+	msg := fmt.Sprintf("Reinitialized %q with keys %q.", dir, fingerprints)
 	cb := func() error {
-		for _, file := range ps.gitFiles {
-			if !strings.HasSuffix(file, ".gpg") {
-				continue
-			}
-			fileDir := filepath.Clean(filepath.Join(ps.store.RootDir(), filepath.Dir(file)))
-			if fileDir != dirPath {
-				log.Printf("file %q is skipped cause it is not in the directory %q", file, directory)
-				continue
-			}
+		var skipped []string
+		for _, file := range files {
 			// Read the file, decrypt the content and reencrypt it with new fingerprints.
 			oldEncrypted, err := ps.store.ReadFile(file)
 			if err != nil {
 				return xerrors.Errorf("could not read file %q: %w", file, err)
 			}
-			decrypted, err := ps.keyring.Decrypt(oldEncrypted)
+			decrypted, err := ps.decrypt(file, oldEncrypted)
 			if err != nil {
-				if nskip--; nskip <= 0 {
+				if len(skipped) >= nskip {
 					return xerrors.Errorf("could not decrypt file %q: %w", file, err)
 				}
+				skipped = append(skipped, file)
+				continue
 			}
-			newEncrypted, err := ps.keyring.Encrypt(decrypted, fingerprints)
+			newFile := strings.TrimSuffix(file, filepath.Ext(file)) + ext
+			newEncrypted, err := ps.encrypt(newFile, decrypted, fingerprints)
 			if err != nil {
 				return xerrors.Errorf("could not reencrypt file %q: %w", file, err)
 			}
-			if err := ps.store.UpdateFile(file, newEncrypted); err != nil {
-				return xerrors.Errorf("could not update file %q: %w", file, err)
+			if newFile == file {
+				if err := ps.store.UpdateFile(file, newEncrypted); err != nil {
+					return xerrors.Errorf("could not update file %q: %w", file, err)
+				}
+				log.Printf("re-encrypted %q with new keys", file)
+				continue
 			}
+			stat, err := ps.store.Stat(file)
+			if err != nil {
+				return xerrors.Errorf("could not stat file %q: %w", file, err)
+			}
+			if err := ps.store.WriteFile(newFile, newEncrypted, stat.Mode()); err != nil {
+				return xerrors.Errorf("could not write file %q: %w", newFile, err)
+			}
+			if err := ps.store.RemoveFile(file); err != nil {
+				return xerrors.Errorf("could not remove file %q: %w", file, err)
+			}
+			log.Printf("converted %q into %q with new keys", file, newFile)
 		}
-		// Also update the .gpg-id file.
-		idFile := filepath.Clean(filepath.Join(directory, ".gpg-id"))
+		if len(files) > 0 && len(skipped) == len(files) {
+			return xerrors.Errorf("could not decrypt any file: %w", os.ErrInvalid)
+		}
+		if len(skipped) > 0 {
+			log.Printf("warning: could not decrypt files %q, so they are skipped", skipped)
+		}
+		// Also create or update the .gpg-id or .age-recipients file.
+		idFile := filepath.Join(dir, idFileName)
 		content := strings.Join(fingerprints, "\n") + "\n"
-		if err := ps.store.UpdateFile(idFile, []byte(content)); err != nil {
-			return xerrors.Errorf("could not update the gpg ids file %q: %w", idFile, err)
+		if err := ps.store.WriteFile(idFile, []byte(content), os.FileMode(0644)); err != nil {
+			return xerrors.Errorf("could not update the keys file %q: %w", idFile, err)
+		}
+		if idFileName == ".gpg-id" {
+			if err := ps.signKeysFile(idFile, []byte(content)); err != nil {
+				return xerrors.Errorf("could not sign the keys file %q: %w", idFile, err)
+			}
 		}
 		return nil
 	}
 	if err := ps.store.Apply(msg, cb); err != nil {
 		return xerrors.Errorf("could not reinitialize the directory %q: %w", directory, err)
 	}
+	if ext == ".age" {
+		ps.ageDirKeysMap[dir] = append([]string{}, fingerprints...)
+	} else {
+		ps.dirKeysMap[dir] = append([]string{}, fingerprints...)
+	}
 	return nil
 }
 
@@ -402,6 +1027,32 @@ func Format(password string, data []byte) []byte {
 	return buf.Bytes()
 }
 
+// SetValue returns the password file content with the value for a key
+// replaced, or added at the end when the key doesn't exist. Unlike the Values
+// type, password and other lines of the content are kept unchanged.
+func SetValue(decrypted []byte, key, value string) []byte {
+	password, data := Parse(decrypted)
+	line := key + ":" + strings.Replace(value, "\n", "\n\t", -1)
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		colon := strings.IndexRune(lines[i], ':')
+		if colon < 0 || strings.HasPrefix(lines[i], "\t") || strings.TrimSpace(lines[i][:colon]) != key {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && strings.HasPrefix(lines[end], "\t") {
+			end++
+		}
+		lines = append(lines[:i], append([]string{line}, lines[end:]...)...)
+		return Format(password, []byte(strings.Join(lines, "\n")))
+	}
+	rest := string(data)
+	if len(rest) > 0 && !strings.HasSuffix(rest, "\n") {
+		rest += "\n"
+	}
+	return Format(password, []byte(rest+line+"\n"))
+}
+
 // Parse splits the decrypted password file content into a password and
 // additional user data.
 func Parse(decrypted []byte) (string, []byte) {
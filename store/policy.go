@@ -0,0 +1,322 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pelletier/go-toml"
+	"golang.org/x/xerrors"
+)
+
+// PolicyFile is the name of the password policy files. A policy file applies
+// to the password files in its directory and the subdirectories, unless a
+// subdirectory has its own policy file, like the .gpg-id files.
+const PolicyFile = ".past-policy"
+
+// RotatedAtKey is the key for the time when the password in a password file
+// was last changed.
+const RotatedAtKey = "rotated_at"
+
+// ErrPolicyViolation is returned when a password file content doesn't satisfy
+// the password policy for its directory.
+var ErrPolicyViolation = errors.New("password file violates the password policy")
+
+// Policy holds the rules for the password files from a policy file, which is
+// in the TOML format, like
+//
+//	min_length = 16
+//	required_classes = ["upper", "lower", "digit", "symbol"]
+//	required_keys = ["username", "url"]
+//	max_age_days = 90
+type Policy struct {
+	// MinLength is the minimum number of characters in the passwords.
+	MinLength int `toml:"min_length"`
+
+	// RequiredClasses are the character classes that must be used in the
+	// passwords. Classes are "upper", "lower", "digit" and "symbol".
+	RequiredClasses []string `toml:"required_classes"`
+
+	// RequiredKeys are the keys that must have a value in the password files.
+	RequiredKeys []string `toml:"required_keys"`
+
+	// MaxAgeDays is the maximum number of days since the password was last
+	// changed. Password files with older passwords can only be updated with a
+	// new password.
+	MaxAgeDays int `toml:"max_age_days"`
+}
+
+// policyClasses holds the functions to identify the characters of each
+// character class in the policies.
+var policyClasses = map[string]func(rune) bool{
+	"upper": unicode.IsUpper,
+	"lower": unicode.IsLower,
+	"digit": unicode.IsDigit,
+	"symbol": func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+	},
+}
+
+// ParsePolicy parses the content of a policy file. Unknown keys are rejected,
+// so that misspelled rules are not silently ignored.
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := new(Policy)
+	if err := toml.NewDecoder(bytes.NewReader(data)).Strict(true).Decode(p); err != nil {
+		return nil, xerrors.Errorf("could not parse policy: %w", err)
+	}
+	for _, class := range p.RequiredClasses {
+		if _, ok := policyClasses[class]; !ok {
+			return nil, xerrors.Errorf("unknown character class %q in policy: %w", class, os.ErrInvalid)
+		}
+	}
+	return p, nil
+}
+
+// Check returns ErrPolicyViolation if a password file content doesn't satisfy
+// the policy. Age is the time since the password was last changed, which is
+// zero for the new passwords.
+func (p *Policy) Check(data []byte, age time.Duration) error {
+	password, rest := Parse(data)
+	if len([]rune(password)) < p.MinLength {
+		return xerrors.Errorf("password must have at least %d characters: %w", p.MinLength, ErrPolicyViolation)
+	}
+	for _, class := range p.RequiredClasses {
+		if is := policyClasses[class]; is != nil && strings.IndexFunc(password, is) < 0 {
+			return xerrors.Errorf("password must have %s characters: %w", class, ErrPolicyViolation)
+		}
+	}
+
+	vs := NewValues(rest)
+	for _, key := range p.RequiredKeys {
+		if !hasValue(vs, key) {
+			return xerrors.Errorf("password file must have a %q value: %w", key, ErrPolicyViolation)
+		}
+	}
+
+	if p.MaxAgeDays > 0 && age > time.Duration(p.MaxAgeDays)*24*time.Hour {
+		return xerrors.Errorf("password is older than %d days and must be rotated: %w", p.MaxAgeDays, ErrPolicyViolation)
+	}
+	return nil
+}
+
+// hasValue returns true if a key has a non-empty value. Keys are matched in
+// case-insensitive form and the aliases for the usernames and addresses are
+// accepted.
+func hasValue(vs *Values, key string) bool {
+	switch strings.ToLower(key) {
+	case "username", "user", "login":
+		for _, v := range GetUsernames(vs) {
+			if len(v) > 0 {
+				return true
+			}
+		}
+	case "url", "website":
+		for _, v := range GetURLs(vs) {
+			if len(v) > 0 {
+				return true
+			}
+		}
+	}
+	for _, k := range vs.Keys() {
+		if strings.EqualFold(k, key) && len(vs.Get(k)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// FilePolicy returns the policy from the nearest policy file for a password
+// file. It returns nil if no directory of the password file has a policy file.
+func (ps *PasswordStore) FilePolicy(path string) (*Policy, error) {
+	return ps.filePolicy(path, nil)
+}
+
+// filePolicy is similar to FilePolicy, but the policy files for the
+// directories in the moved map are read from the mapped source directories.
+func (ps *PasswordStore) filePolicy(path string, moved map[string]string) (*Policy, error) {
+	name := filepath.Clean(filepath.Join("./", path))
+	for d := filepath.Dir(name); ; d = filepath.Dir(d) {
+		src := d
+		if dir, ok := moved[d]; ok {
+			src = dir
+		}
+		file := filepath.Join(src, PolicyFile)
+		if yes, _ := ps.FileExists(file); yes {
+			data, err := ps.store.ReadFile(file)
+			if err != nil {
+				return nil, xerrors.Errorf("could not read policy file %q: %w", file, err)
+			}
+			p, err := ParsePolicy(data)
+			if err != nil {
+				return nil, xerrors.Errorf("could not parse policy file %q: %w", file, err)
+			}
+			return p, nil
+		}
+		if d == "." {
+			return nil, nil
+		}
+	}
+}
+
+// SetRotatedAt returns the password file content with the rotated_at value
+// set to the input time. Other lines of the content are kept unchanged.
+func SetRotatedAt(data []byte, t time.Time) []byte {
+	return SetValue(data, RotatedAtKey, t.UTC().Format(time.RFC3339))
+}
+
+// prepareFile returns the content for a password file with the rotated_at
+// value set for the new password files or when the password is different from
+// the current content, or kept from the current content otherwise, and
+// verifies it with the policy. Oldfile is the git file path for the current
+// content, which is empty for the new password files. When skipAge is true,
+// password must be unchanged and its age is not checked.
+func (ps *PasswordStore) prepareFile(file, oldfile string, data []byte, skipAge bool) ([]byte, error) {
+	var old []byte
+	if len(oldfile) == 0 {
+		data = SetRotatedAt(data, time.Now())
+	} else {
+		decrypted, err := ps.decryptFile(oldfile)
+		if err != nil {
+			return nil, err
+		}
+		password, rest := Parse(data)
+		oldPassword, oldRest := Parse(decrypted)
+		if skipAge && oldPassword != password {
+			return nil, xerrors.Errorf("password in file %q cannot be changed without the age check: %w", file, os.ErrInvalid)
+		}
+		if oldPassword != password {
+			data = SetRotatedAt(data, time.Now())
+		} else if len(NewValues(rest).Get(RotatedAtKey)) == 0 {
+			if rotatedAt, err := time.Parse(time.RFC3339, NewValues(oldRest).Get(RotatedAtKey)); err == nil {
+				data = SetRotatedAt(data, rotatedAt)
+			}
+		}
+		old = decrypted
+	}
+	p, err := ps.FilePolicy(file)
+	if err != nil {
+		return nil, err
+	}
+	if skipAge {
+		old = nil
+	}
+	if err := ps.checkFilePolicy(p, file, oldfile, old, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// checkPolicy verifies the content for a password file with the policy for
+// its directory. Oldfile is the git file path for the current content, which
+// is empty for the new password files.
+func (ps *PasswordStore) checkPolicy(file, oldfile string, data []byte) error {
+	p, err := ps.FilePolicy(file)
+	if err != nil {
+		return err
+	}
+	var old []byte
+	if p != nil && p.MaxAgeDays > 0 && len(oldfile) > 0 {
+		if old, err = ps.decryptFile(oldfile); err != nil {
+			return err
+		}
+	}
+	return ps.checkFilePolicy(p, file, oldfile, old, data)
+}
+
+// checkFilePolicy is similar to checkPolicy, but uses the input policy, which
+// can be nil, and the decrypted content of the oldfile.
+func (ps *PasswordStore) checkFilePolicy(p *Policy, file, oldfile string, old, data []byte) error {
+	if p == nil {
+		return nil
+	}
+	var age time.Duration
+	if p.MaxAgeDays > 0 {
+		d, err := ps.passwordAge(oldfile, old, data)
+		if err != nil {
+			return err
+		}
+		age = d
+	}
+	if err := p.Check(data, age); err != nil {
+		return xerrors.Errorf("password file %q doesn't satisfy the policy: %w", file, err)
+	}
+	return nil
+}
+
+// passwordAge returns the time since the password in the content was last
+// changed, which is zero when the password is different from the old content.
+// Age is taken from the rotated_at value of the old content or from the history
+// of the oldfile when the rotated_at value is missing.
+func (ps *PasswordStore) passwordAge(oldfile string, old, data []byte) (time.Duration, error) {
+	if old == nil {
+		return 0, nil
+	}
+	password, _ := Parse(data)
+	oldPassword, rest := Parse(old)
+	if oldPassword != password {
+		return 0, nil
+	}
+	if rotatedAt, err := time.Parse(time.RFC3339, NewValues(rest).Get(RotatedAtKey)); err == nil {
+		return time.Since(rotatedAt), nil
+	}
+	changedAt, err := ps.passwordChangedAt(oldfile, oldPassword)
+	if err != nil {
+		return 0, err
+	}
+	if changedAt.IsZero() {
+		return 0, nil
+	}
+	return time.Since(changedAt), nil
+}
+
+// passwordChangedAt returns the time of the commit that changed the password
+// in a password file to the input password, which is the oldest commit in the
+// latest run of commits with the same password. It returns zero time if the
+// password file has no commits.
+func (ps *PasswordStore) passwordChangedAt(file, password string) (time.Time, error) {
+	items, err := ps.store.Log(file)
+	if err != nil {
+		return time.Time{}, xerrors.Errorf("could not get log for %q: %w", file, err)
+	}
+	var changedAt time.Time
+	for _, item := range items {
+		encrypted, err := ps.store.ReadFileAt(item.Commit, file)
+		if err != nil {
+			if xerrors.Is(err, os.ErrNotExist) {
+				break
+			}
+			return time.Time{}, xerrors.Errorf("could not read file %q at %q: %w", file, item.Commit, err)
+		}
+		// Older revisions may be encrypted for the keys that are not available
+		// anymore, in which case the password is assumed to be changed.
+		decrypted, err := ps.decrypt(file, encrypted)
+		if err != nil {
+			break
+		}
+		if old, _ := Parse(decrypted); old != password {
+			break
+		}
+		changedAt = item.AuthorDate
+	}
+	return changedAt, nil
+}
+
+// decryptFile returns the unencrypted content of a password file at a git file
+// path.
+func (ps *PasswordStore) decryptFile(file string) ([]byte, error) {
+	encrypted, err := ps.store.ReadFile(file)
+	if err != nil {
+		return nil, xerrors.Errorf("could not read file %q: %w", file, err)
+	}
+	decrypted, err := ps.decrypt(file, encrypted)
+	if err != nil {
+		return nil, xerrors.Errorf("could not decrypt file %q: %w", file, err)
+	}
+	return decrypted, nil
+}
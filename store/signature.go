@@ -0,0 +1,238 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bvk/past/gpg"
+	"golang.org/x/xerrors"
+)
+
+// ErrBadSignature is returned when the keys from a .gpg-id file are not used
+// cause the .gpg-id file is not signed by one of the signing keys.
+var ErrBadSignature = errors.New("keys file is not signed by a signing key")
+
+// ErrUntrustedCommit is returned when a commit is not signed or is signed by a
+// key that is not in the .gpg-id files of the password store.
+var ErrUntrustedCommit = errors.New("commit is not signed by a password store key")
+
+// Signer is the interface for the encryption backends that can sign and
+// verify the .gpg-id files.
+type Signer interface {
+	// Sign returns a detached signature for the input data with a secret key.
+	Sign(data []byte, key string) ([]byte, error)
+
+	// Verify checks a detached signature for the input data and returns the
+	// fingerprints of the signing key.
+	Verify(data, signature []byte) ([]string, error)
+}
+
+// SignFile returns a detached signature for the input data with the first
+// signing key that can sign.
+func SignFile(signer Signer, data []byte, keys []string) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, xerrors.Errorf("at least one signing key is required: %w", os.ErrInvalid)
+	}
+	var err error
+	for _, key := range keys {
+		var signature []byte
+		if signature, err = signer.Sign(data, key); err == nil {
+			return signature, nil
+		}
+	}
+	return nil, xerrors.Errorf("could not sign with any of the signing keys %q: %w", keys, err)
+}
+
+// SetSigningKeys sets the keys that must sign the .gpg-id files, like the
+// PASSWORD_STORE_SIGNING_KEY environment variable in pass. When signing keys
+// are set, keys from a .gpg-id file are used only if the .gpg-id.sig file has
+// a valid signature from one of the signing keys, so that keys added to the
+// repository by others are not trusted without a signature. Reinit signs the
+// updated .gpg-id files with the signing keys.
+//
+// Signing keys can be given as fingerprints, key ids, emails or user names
+// and are resolved to the fingerprints of the matching keys and their
+// subkeys. It returns an error if a signing key doesn't match any key.
+func (ps *PasswordStore) SetSigningKeys(keys []string) error {
+	fingerprints := make(map[string]bool)
+	if len(keys) > 0 {
+		if ps.crypter == nil {
+			return xerrors.Errorf("gpg keyring is required for the signing keys: %w", os.ErrInvalid)
+		}
+		groups := keyGroups(ps.crypter.PublicKeys())
+		for _, key := range keys {
+			found := false
+			for _, group := range groups {
+				if !matchesKey(group, key) {
+					continue
+				}
+				for _, pk := range group {
+					fingerprints[strings.ToUpper(pk.Fingerprint)] = true
+				}
+				found = true
+			}
+			if !found {
+				return xerrors.Errorf("could not find signing key %q in the keyring: %w", key, os.ErrNotExist)
+			}
+		}
+	}
+	ps.signingKeys = append([]string{}, keys...)
+	ps.signingFingerprints = fingerprints
+	ps.verifiedDirs = make(map[string]bool)
+	return nil
+}
+
+// keyGroups returns the public keys grouped as a primary key followed by its
+// subkeys, which is the order the keys are listed in.
+func keyGroups(pks []*gpg.PublicKey) [][]*gpg.PublicKey {
+	var groups [][]*gpg.PublicKey
+	for _, pk := range pks {
+		if !pk.Subkey || len(groups) == 0 {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], pk)
+	}
+	return groups
+}
+
+// matchesKey returns true if a key identifier matches the fingerprint or the
+// key id of a key in the group, or the email or the user name of the group.
+func matchesKey(group []*gpg.PublicKey, key string) bool {
+	id := strings.ToUpper(strings.TrimPrefix(key, "0x"))
+	email := strings.ToLower(strings.Trim(key, "<>"))
+	for _, pk := range group {
+		if len(id) >= 8 && (strings.HasSuffix(strings.ToUpper(pk.Fingerprint), id) || strings.ToUpper(pk.KeyID) == id) {
+			return true
+		}
+		if len(pk.UserEmail) > 0 && strings.ToLower(pk.UserEmail) == email {
+			return true
+		}
+		if len(pk.UserName) > 0 && pk.UserName == key {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyKeysFile checks that the .gpg-id file in a directory is signed by one
+// of the signing keys.
+func (ps *PasswordStore) verifyKeysFile(dir string) error {
+	if len(ps.signingKeys) == 0 || ps.verifiedDirs[dir] {
+		return nil
+	}
+	signer, ok := ps.crypter.(Signer)
+	if !ok {
+		return xerrors.Errorf("encryption backend cannot verify signatures: %w", os.ErrInvalid)
+	}
+
+	file := filepath.Join(dir, ".gpg-id")
+	data, err := ps.store.ReadFile(file)
+	if err != nil {
+		return xerrors.Errorf("could not read keys file %q: %w", file, err)
+	}
+	signature, err := ps.store.ReadFile(file + ".sig")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return xerrors.Errorf("signature file for %q doesn't exist: %w", file, ErrBadSignature)
+		}
+		return xerrors.Errorf("could not read signature file for %q: %w", file, err)
+	}
+	fps, err := signer.Verify(data, signature)
+	if err != nil {
+		return xerrors.Errorf("signature for %q is invalid (%v): %w", file, err, ErrBadSignature)
+	}
+	if !ps.isSigningKey(fps) {
+		return xerrors.Errorf("signature for %q is from an unknown key %q: %w", file, fps, ErrBadSignature)
+	}
+	ps.verifiedDirs[dir] = true
+	return nil
+}
+
+// isSigningKey returns true if any of the fingerprints belongs to a signing
+// key.
+func (ps *PasswordStore) isSigningKey(fps []string) bool {
+	for _, fp := range fps {
+		if ps.signingFingerprints[strings.ToUpper(fp)] {
+			return true
+		}
+	}
+	return false
+}
+
+// signKeysFile writes the signature file for a .gpg-id file with the new
+// content when signing keys are set.
+func (ps *PasswordStore) signKeysFile(file string, data []byte) error {
+	if len(ps.signingKeys) == 0 {
+		return nil
+	}
+	signer, ok := ps.crypter.(Signer)
+	if !ok {
+		return xerrors.Errorf("encryption backend cannot create signatures: %w", os.ErrInvalid)
+	}
+	signature, err := SignFile(signer, data, ps.signingKeys)
+	if err != nil {
+		return xerrors.Errorf("could not sign keys file %q: %w", file, err)
+	}
+	if err := ps.store.WriteFile(file+".sig", signature, os.FileMode(0644)); err != nil {
+		return xerrors.Errorf("could not write signature file for %q: %w", file, err)
+	}
+	delete(ps.verifiedDirs, filepath.Dir(file))
+	return nil
+}
+
+// VerifyCommits checks that all commits reachable from the to ref, but not
+// from the from ref, have a good signature from one of the keys in the .gpg-id
+// files of the password store. Subkeys of the .gpg-id keys and the keys with
+// subkeys in the .gpg-id files are also accepted. Keys are taken from the
+// current checkout, so that incoming commits cannot add new signers.
+func (ps *PasswordStore) VerifyCommits(from, to string) error {
+	if ps.crypter == nil {
+		return xerrors.Errorf("gpg keyring is required to verify commits: %w", os.ErrInvalid)
+	}
+	sigs, err := ps.store.CommitSignatures(from, to)
+	if err != nil {
+		return xerrors.Errorf("could not get commit signatures: %w", err)
+	}
+	trusted := ps.trustedSigners()
+	for _, sig := range sigs {
+		if sig.Status != "G" && sig.Status != "U" {
+			return xerrors.Errorf("commit %s doesn't have a good signature (status %q): %w", sig.Commit, sig.Status, ErrUntrustedCommit)
+		}
+		if !trusted[strings.ToUpper(sig.Fingerprint)] && !trusted[strings.ToUpper(sig.PrimaryFingerprint)] {
+			return xerrors.Errorf("commit %s is signed by an unknown key %q: %w", sig.Commit, sig.Fingerprint, ErrUntrustedCommit)
+		}
+	}
+	return nil
+}
+
+// trustedSigners returns the fingerprints of the keys in the .gpg-id files
+// along with the fingerprints of their primary keys and subkeys.
+func (ps *PasswordStore) trustedSigners() map[string]bool {
+	ids := make(map[string]bool)
+	for _, keys := range ps.dirKeysMap {
+		for _, key := range keys {
+			ids[strings.ToUpper(key)] = true
+		}
+	}
+	matches := func(pk *gpg.PublicKey) bool {
+		return ids[strings.ToUpper(pk.Fingerprint)] || ids[strings.ToUpper(pk.KeyID)]
+	}
+
+	trusted := make(map[string]bool)
+	for _, group := range keyGroups(ps.crypter.PublicKeys()) {
+		found := false
+		for _, pk := range group {
+			found = found || matches(pk)
+		}
+		if found {
+			for _, pk := range group {
+				trusted[strings.ToUpper(pk.Fingerprint)] = true
+			}
+		}
+	}
+	return trusted
+}
@@ -103,6 +103,16 @@ func (vs *Values) Del(key string) {
 	}
 }
 
+// Keys returns all keys in sorted order.
+func (vs *Values) Keys() []string {
+	var keys []string
+	for k := range vs.m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // Len returns the number of key-value pairs.
 func (vs *Values) Len() int {
 	return len(vs.m)
@@ -121,3 +131,17 @@ func GetUsernames(vs *Values) []string {
 	}
 	return users
 }
+
+// GetURLs is a helper function to identify the website addresses. One of the
+// "url" or "website" keys (in case-insensitive form) identify an address.
+func GetURLs(vs *Values) []string {
+	var urls []string
+	for k, v := range vs.m {
+		kk := strings.ToLower(k)
+		if kk == "url" || kk == "website" {
+			urls = append(urls, v)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/xerrors"
+)
+
+// AllStores is the store name to search across all password stores.
+const AllStores = "*"
+
+// StoreProfile is a named password store with its own data directory and
+// optional keyring and remote.
+type StoreProfile struct {
+	Name    string `json:"name"`
+	DataDir string `json:"data_dir"`
+
+	// Backend and KeyringFiles are the --backend and --keyring-file flag values
+	// for the store. Defaults are used when empty.
+	Backend      string   `json:"backend,omitempty"`
+	KeyringFiles []string `json:"keyring_files,omitempty"`
+
+	AgeIdentityFile string `json:"age_identity_file,omitempty"`
+
+	// Remote is the git remote used for sync when it is not empty. Upstream of
+	// the current branch is used otherwise.
+	Remote string `json:"remote,omitempty"`
+}
+
+type storesConfig struct {
+	Stores []*StoreProfile `json:"stores"`
+}
+
+// storesFile returns the path to the file with the store profiles.
+func storesFile() string {
+	return filepath.Join(os.Getenv("HOME"), ".config/past/stores.json")
+}
+
+// loadStores returns the store profiles sorted by their names. Missing stores
+// file is not an error.
+func loadStores() ([]*StoreProfile, error) {
+	file := storesFile()
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, xerrors.Errorf("could not read stores file %q: %w", file, err)
+	}
+	config := new(storesConfig)
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, xerrors.Errorf("could not parse stores file %q: %w", file, err)
+	}
+	sort.Slice(config.Stores, func(i, j int) bool {
+		return config.Stores[i].Name < config.Stores[j].Name
+	})
+	return config.Stores, nil
+}
+
+// saveStores replaces the stores file with the input store profiles.
+func saveStores(profiles []*StoreProfile) error {
+	file := storesFile()
+	data, err := json.MarshalIndent(&storesConfig{Stores: profiles}, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("could not marshal store profiles: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), os.FileMode(0700)); err != nil {
+		return xerrors.Errorf("could not create directory for %q: %w", file, err)
+	}
+	temp := file + ".tmp"
+	if err := ioutil.WriteFile(temp, append(data, '\n'), os.FileMode(0600)); err != nil {
+		return xerrors.Errorf("could not write stores file %q: %w", temp, err)
+	}
+	if err := os.Rename(temp, file); err != nil {
+		return xerrors.Errorf("could not replace stores file %q: %w", file, err)
+	}
+	return nil
+}
+
+// findStore returns the store profile with the input name.
+func findStore(name string) (*StoreProfile, error) {
+	profiles, err := loadStores()
+	if err != nil {
+		return nil, xerrors.Errorf("could not load store profiles: %w", err)
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, xerrors.Errorf("store %q is not found in %q: %w", name, storesFile(), os.ErrNotExist)
+}
+
+// applyStore updates the flags with the values from the store profile selected
+// by the --store flag. Flags given on the command line are not overwritten.
+func applyStore(flags *pflag.FlagSet) error {
+	name, err := flags.GetString("store")
+	if err != nil {
+		return xerrors.Errorf("could not get --store value: %w", err)
+	}
+	if len(name) == 0 {
+		return nil
+	}
+	profile, err := findStore(name)
+	if err != nil {
+		return err
+	}
+
+	values := map[string][]string{
+		"data-dir":          {profile.DataDir},
+		"backend":           {profile.Backend},
+		"keyring-file":      profile.KeyringFiles,
+		"age-identity-file": {profile.AgeIdentityFile},
+	}
+	for flag, vs := range values {
+		if flags.Changed(flag) {
+			continue
+		}
+		for _, v := range vs {
+			if len(v) == 0 {
+				continue
+			}
+			if err := flags.Set(flag, v); err != nil {
+				return xerrors.Errorf("could not set --%s value from store %q: %w", flag, name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2020 BVK Chaitanya
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+var storesCmd = &cobra.Command{
+	Use:   "stores [flags]",
+	Short: "Prints the named password stores.",
+	RunE:  cmdStores,
+}
+
+var storesAddCmd = &cobra.Command{
+	Use:   "add [flags] <name> <data-dir>",
+	Short: "Adds or updates a named password store.",
+	Long: `Adds or updates a named password store.
+
+Values of the --backend, --keyring-file and --age-identity-file flags, when
+given, are saved with the store and are used for all commands with the --store
+flag.`,
+	RunE: cmdStoresAdd,
+}
+
+var storesRemoveCmd = &cobra.Command{
+	Use:   "remove [flags] <name>",
+	Short: "Removes a named password store. Password-files are not removed.",
+	RunE:  cmdStoresRemove,
+}
+
+func init() {
+	flags := storesAddCmd.Flags()
+	flags.String("remote", "", "Git remote name to sync the password store with.")
+
+	storesCmd.AddCommand(storesAddCmd)
+	storesCmd.AddCommand(storesRemoveCmd)
+}
+
+func cmdStores(cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return xerrors.Errorf("unexpected arguments: %w", os.ErrInvalid)
+	}
+	profiles, err := loadStores()
+	if err != nil {
+		return xerrors.Errorf("could not load store profiles: %w", err)
+	}
+	for _, p := range profiles {
+		backend := p.Backend
+		if len(backend) == 0 {
+			backend = "gpg"
+		}
+		fmt.Printf("%s\t%s\tbackend:%s", p.Name, p.DataDir, backend)
+		if len(p.Remote) > 0 {
+			fmt.Printf("\tremote:%s", p.Remote)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func cmdStoresAdd(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	if len(args) != 2 {
+		return xerrors.Errorf("store name and data directory arguments are required: %w", os.ErrInvalid)
+	}
+	name := args[0]
+	if len(name) == 0 || name == AllStores {
+		return xerrors.Errorf("invalid store name %q: %w", name, os.ErrInvalid)
+	}
+	dataDir, err := filepath.Abs(args[1])
+	if err != nil {
+		return xerrors.Errorf("could not determine absolute path for %q: %w", args[1], err)
+	}
+
+	profile := &StoreProfile{Name: name, DataDir: dataDir}
+	if profile.Remote, err = flags.GetString("remote"); err != nil {
+		return xerrors.Errorf("could not get --remote value: %w", err)
+	}
+	if flags.Changed("backend") {
+		if profile.Backend, err = flags.GetString("backend"); err != nil {
+			return xerrors.Errorf("could not get --backend value: %w", err)
+		}
+	}
+	if flags.Changed("keyring-file") {
+		if profile.KeyringFiles, err = flags.GetStringSlice("keyring-file"); err != nil {
+			return xerrors.Errorf("could not get --keyring-file value: %w", err)
+		}
+	}
+	if flags.Changed("age-identity-file") {
+		if profile.AgeIdentityFile, err = flags.GetString("age-identity-file"); err != nil {
+			return xerrors.Errorf("could not get --age-identity-file value: %w", err)
+		}
+	}
+
+	profiles, err := loadStores()
+	if err != nil {
+		return xerrors.Errorf("could not load store profiles: %w", err)
+	}
+	var newProfiles []*StoreProfile
+	for _, p := range profiles {
+		if p.Name != name {
+			newProfiles = append(newProfiles, p)
+		}
+	}
+	newProfiles = append(newProfiles, profile)
+	if err := saveStores(newProfiles); err != nil {
+		return xerrors.Errorf("could not save store profiles: %w", err)
+	}
+	return nil
+}
+
+func cmdStoresRemove(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return xerrors.Errorf("store name argument is required: %w", os.ErrInvalid)
+	}
+	name := args[0]
+
+	profiles, err := loadStores()
+	if err != nil {
+		return xerrors.Errorf("could not load store profiles: %w", err)
+	}
+	var newProfiles []*StoreProfile
+	for _, p := range profiles {
+		if p.Name != name {
+			newProfiles = append(newProfiles, p)
+		}
+	}
+	if len(newProfiles) == len(profiles) {
+		return xerrors.Errorf("store %q doesn't exist: %w", name, os.ErrNotExist)
+	}
+	if err := saveStores(newProfiles); err != nil {
+		return xerrors.Errorf("could not save store profiles: %w", err)
+	}
+	return nil
+}
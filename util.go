@@ -3,10 +3,16 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/bvk/past/agecrypt"
 	"github.com/bvk/past/git"
 	"github.com/bvk/past/gpg"
+	"github.com/bvk/past/pgp"
 	"github.com/bvk/past/store"
 
 	"github.com/spf13/pflag"
@@ -25,9 +31,138 @@ func newPasswordStore(flags *pflag.FlagSet) (*store.PasswordStore, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("could not create git directory instance: %w", err)
 	}
-	keyring, err := gpg.NewKeyring("")
+	ageCrypter, err := newAgeCrypter(flags)
 	if err != nil {
-		return nil, xerrors.Errorf("could not create gpg key ring instance: %w", err)
+		return nil, xerrors.Errorf("could not create age encryption backend: %w", err)
 	}
-	return store.New(repo, keyring)
+	crypter, err := newCrypter(flags)
+	if err != nil {
+		// GPG keys are optional for the stores with only age encrypted files.
+		ageOnly, aerr := hasOnlyAgeRecipients(repo)
+		if aerr != nil {
+			return nil, aerr
+		}
+		if !ageOnly {
+			return nil, xerrors.Errorf("could not create encryption backend: %w", err)
+		}
+		crypter = nil
+	}
+	ps, err := store.New(repo, crypter, ageCrypter)
+	if err != nil {
+		return nil, err
+	}
+	ps.SetKeys(envKeys())
+
+	signingKeys, err := flags.GetStringSlice("signing-key")
+	if err != nil {
+		return nil, xerrors.Errorf("could not get --signing-key value: %w", err)
+	}
+	if err := ps.SetSigningKeys(signingKeys); err != nil {
+		return nil, xerrors.Errorf("could not use signing keys %q: %w", signingKeys, err)
+	}
+
+	config, err := loadConfig(dataDir)
+	if err != nil {
+		return nil, xerrors.Errorf("could not load config: %w", err)
+	}
+	config.setupRepo(repo, ps)
+	return ps, nil
+}
+
+// hasOnlyAgeRecipients returns true if the password store has no .gpg-id
+// files, so that all password files are encrypted with age.
+func hasOnlyAgeRecipients(repo *git.Dir) (bool, error) {
+	files, err := repo.ListFiles()
+	if err != nil {
+		return false, xerrors.Errorf("could not list files in the git directory: %w", err)
+	}
+	for _, file := range files {
+		if filepath.Base(file) == ".gpg-id" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// newAgeCrypter returns the age encryption backend with the identities from
+// --age-identity-file flag. Passphrase is prompted on the terminal for scrypt
+// encrypted files.
+func newAgeCrypter(flags *pflag.FlagSet) (*agecrypt.Keyring, error) {
+	file, err := flags.GetString("age-identity-file")
+	if err != nil {
+		return nil, xerrors.Errorf("could not get --age-identity-file value: %w", err)
+	}
+	passphrase := func() (string, error) {
+		return getPassword("Passphrase:")
+	}
+	keyring, err := agecrypt.NewKeyring(file, passphrase)
+	if err != nil {
+		return nil, xerrors.Errorf("could not create age key ring instance: %w", err)
+	}
+	return keyring, nil
+}
+
+// newCrypter returns the encryption backend selected by the --backend flag.
+func newCrypter(flags *pflag.FlagSet) (store.Crypter, error) {
+	backend, err := flags.GetString("backend")
+	if err != nil {
+		return nil, xerrors.Errorf("could not get --backend value: %w", err)
+	}
+	switch backend {
+	case "gpg":
+		keyring, err := gpg.NewKeyringWithOptions("", gpgOptions())
+		if err != nil {
+			return nil, xerrors.Errorf("could not create gpg key ring instance: %w", err)
+		}
+		return keyring, nil
+	case "native":
+		files, err := flags.GetStringSlice("keyring-file")
+		if err != nil {
+			return nil, xerrors.Errorf("could not get --keyring-file value: %w", err)
+		}
+		if len(files) == 0 {
+			files = pgp.DefaultKeyringFiles()
+		}
+		passphrase := func(keyID string) (string, error) {
+			return getPassword(fmt.Sprintf("Passphrase for key %s:", keyID))
+		}
+		keyring, err := pgp.NewKeyring(files, passphrase)
+		if err != nil {
+			return nil, xerrors.Errorf("could not create native key ring instance from %q: %w", files, err)
+		}
+		return keyring, nil
+	default:
+		return nil, xerrors.Errorf("unsupported encryption backend %q: %w", backend, os.ErrInvalid)
+	}
+}
+
+// addGitAttributes adds the missing lines to the .gitattributes file at the
+// top of the repository and commits the change.
+func addGitAttributes(repo *git.Dir, lines []string) error {
+	data, err := repo.ReadFile(".gitattributes")
+	if err != nil && !os.IsNotExist(err) {
+		return xerrors.Errorf("could not read .gitattributes file: %w", err)
+	}
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+	var missing []string
+	for _, line := range lines {
+		if !existing[line] {
+			missing = append(missing, line)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, []byte(strings.Join(missing, "\n")+"\n")...)
+	msg := fmt.Sprintf("Added %q to the .gitattributes file.", missing)
+	return repo.Apply(msg, func() error {
+		return repo.WriteFile(".gitattributes", data, os.FileMode(0644))
+	})
 }